@@ -0,0 +1,90 @@
+// Package apiversion describes the versions of the public API and counts
+// their use, so a version is retired once its traffic is gone.
+package apiversion
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// Header selects the version of a request to an unversioned HTTP path
+	// and reports the version that served a response.
+	Header = "API-Version"
+
+	// Selection labels of the requests metric. The version of gRPC calls is
+	// always selected by path, the package of the method.
+	SelectedByPath    = "path"
+	SelectedByHeader  = "header"
+	SelectedByDefault = "default"
+)
+
+var requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "api_version_requests_total",
+	Help: "Number of API requests by transport, version and how the version was selected (path, header or default).",
+}, []string{"transport", "version", "selection"})
+
+func init() {
+	prometheus.MustRegister(requests)
+}
+
+// Version is a served version of the API.
+type Version struct {
+	Name string
+	// Deprecated is when the version was deprecated, zero while it is
+	// supported.
+	Deprecated time.Time
+	// Sunset is when the version stops being served, zero until it is
+	// scheduled.
+	Sunset time.Time
+	// Link points to the migration guide of a deprecated version.
+	Link string
+}
+
+// IsDeprecated reports whether clients are told to move off v.
+func (v Version) IsDeprecated() bool {
+	return !v.Deprecated.IsZero()
+}
+
+// DeprecationHeaders returns the Deprecation (RFC 9745), Sunset (RFC 8594)
+// and Link headers announcing the retirement of v, none while v is
+// supported.
+func (v Version) DeprecationHeaders() http.Header {
+	h := http.Header{}
+	if !v.IsDeprecated() {
+		return h
+	}
+	h.Set("Deprecation", "@"+strconv.FormatInt(v.Deprecated.Unix(), 10))
+	if !v.Sunset.IsZero() {
+		h.Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if v.Link != "" {
+		h.Set("Link", "<"+v.Link+`>; rel="deprecation"`)
+	}
+	return h
+}
+
+// Observe counts a request served by version over transport.
+func Observe(transport, version, selection string) {
+	requests.WithLabelValues(transport, version, selection).Inc()
+}
+
+type selectionKey struct{}
+
+// WithSelection records how the version of a request was selected.
+func WithSelection(ctx context.Context, selection string) context.Context {
+	return context.WithValue(ctx, selectionKey{}, selection)
+}
+
+// Selection returns how the version of a request was selected,
+// SelectedByPath unless WithSelection recorded otherwise.
+func Selection(ctx context.Context) string {
+	if s, ok := ctx.Value(selectionKey{}).(string); ok {
+		return s
+	}
+	return SelectedByPath
+}
@@ -0,0 +1,32 @@
+package apiversion
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersion_DeprecationHeaders(t *testing.T) {
+	require.Empty(t, Version{Name: "v2"}.DeprecationHeaders())
+
+	h := Version{
+		Name:       "v1",
+		Deprecated: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+		Sunset:     time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC),
+		Link:       "https://example.com/migrate-to-v2",
+	}.DeprecationHeaders()
+	require.Equal(t, http.Header{
+		"Deprecation": {"@1792368000"},
+		"Sunset":      {"Thu, 01 Apr 2027 00:00:00 GMT"},
+		"Link":        {`<https://example.com/migrate-to-v2>; rel="deprecation"`},
+	}, h)
+}
+
+func TestSelection(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, SelectedByPath, Selection(ctx))
+	require.Equal(t, SelectedByHeader, Selection(WithSelection(ctx, SelectedByHeader)))
+}
@@ -0,0 +1,149 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/ravilushqa/boilerplate/internal/idempotency"
+)
+
+// IdempotencyKeyMetadata carries the key chosen by the client.
+const IdempotencyKeyMetadata = "idempotency-key"
+
+const protoContentType = "application/x-protobuf; proto="
+
+// UnaryIdempotency replays the stored response of a unary call sent again
+// with the same idempotency-key metadata. A duplicate arriving while the first
+// call is still handled fails with Aborted, a key reused with another request
+// with FailedPrecondition. Only successful calls and errors the client has to
+// fix are stored, so transient failures can be retried with the same key.
+func UnaryIdempotency(l *slog.Logger, store idempotency.Store) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		key := idempotencyKey(ctx)
+		if key == "" {
+			return handler(ctx, req)
+		}
+		if len(key) > idempotency.MaxKeyLength {
+			return nil, status.Error(codes.InvalidArgument, "idempotency key is too long")
+		}
+		pm, ok := req.(proto.Message)
+		if !ok {
+			return handler(ctx, req)
+		}
+		b, err := proto.MarshalOptions{Deterministic: true}.Marshal(pm)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		sum := sha256.Sum256(b)
+
+		scoped := info.FullMethod + " " + key
+		stored, err := store.Begin(ctx, scoped, hex.EncodeToString(sum[:]))
+		switch {
+		case errors.Is(err, idempotency.ErrInFlight):
+			return nil, status.Error(codes.Aborted, err.Error())
+		case errors.Is(err, idempotency.ErrMismatch):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		case err != nil:
+			l.Error("[GRPC] idempotency store failed", slog.Any("error", err))
+			return nil, status.Error(codes.Unavailable, "idempotency store unavailable")
+		case stored != nil:
+			_ = grpc.SetHeader(ctx, metadata.Pairs("idempotent-replayed", "true"))
+			return replay(stored)
+		}
+
+		completed := false
+		// released on panics too, the recovery interceptor sits outside
+		defer func() {
+			if completed {
+				return
+			}
+			if err := store.Release(ctx, scoped); err != nil {
+				l.Error("[GRPC] idempotency release failed", slog.Any("error", err))
+			}
+		}()
+		resp, err := handler(ctx, req)
+
+		stored, ok = record(resp, err)
+		if !ok {
+			return resp, err
+		}
+		if serr := store.Complete(ctx, scoped, stored); serr != nil {
+			l.Error("[GRPC] idempotency store failed", slog.Any("error", serr))
+			return resp, err
+		}
+		completed = true
+		return resp, err
+	}
+}
+
+func idempotencyKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(IdempotencyKeyMetadata); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// record encodes a response or error as a stored response. The response type
+// is kept in the Content-Type header to decode it on replay.
+func record(resp interface{}, err error) (*idempotency.Response, bool) {
+	if err != nil {
+		switch status.Code(err) {
+		case codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.FailedPrecondition, codes.OutOfRange:
+		default:
+			return nil, false
+		}
+		b, merr := proto.Marshal(status.Convert(err).Proto())
+		if merr != nil {
+			return nil, false
+		}
+		return &idempotency.Response{Status: int(status.Code(err)), Body: b}, true
+	}
+
+	pm, ok := resp.(proto.Message)
+	if !ok {
+		return nil, false
+	}
+	b, merr := proto.Marshal(pm)
+	if merr != nil {
+		return nil, false
+	}
+	h := http.Header{}
+	h.Set("Content-Type", protoContentType+string(pm.ProtoReflect().Descriptor().FullName()))
+	return &idempotency.Response{Status: int(codes.OK), Header: h, Body: b}, true
+}
+
+func replay(stored *idempotency.Response) (interface{}, error) {
+	if codes.Code(stored.Status) != codes.OK {
+		st := &spb.Status{}
+		if err := proto.Unmarshal(stored.Body, st); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		return nil, status.FromProto(st).Err()
+	}
+
+	name := strings.TrimPrefix(stored.Header.Get("Content-Type"), protoContentType)
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	m := mt.New().Interface()
+	if err := proto.Unmarshal(stored.Body, m); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return m, nil
+}
@@ -0,0 +1,93 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	apiv1 "github.com/ravilushqa/boilerplate/api/v1"
+	"github.com/ravilushqa/boilerplate/internal/idempotency"
+)
+
+func TestUnaryIdempotency(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.v1.Greeter/Greet"}
+	calls := 0
+	handler := func(_ context.Context, req interface{}) (interface{}, error) {
+		calls++
+		name := req.(*apiv1.GreetRequest).Name
+		switch name {
+		case "":
+			return nil, status.Error(codes.InvalidArgument, "name is required")
+		case "flaky":
+			return nil, status.Error(codes.Unavailable, "try again")
+		}
+		return &apiv1.GreetResponse{Message: "Hello " + name}, nil
+	}
+	interceptor := UnaryIdempotency(slog.Default(), idempotency.NewMemoryStore(time.Minute))
+	call := func(key, name string) (interface{}, error) {
+		ctx := context.Background()
+		if key != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(IdempotencyKeyMetadata, key))
+		}
+		return interceptor(ctx, &apiv1.GreetRequest{Name: name}, info, handler)
+	}
+
+	t.Run("replayed", func(t *testing.T) {
+		calls = 0
+		first, err := call("k1", "World")
+		require.NoError(t, err)
+		second, err := call("k1", "World")
+		require.NoError(t, err)
+		require.True(t, proto.Equal(first.(proto.Message), second.(proto.Message)))
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("error replayed", func(t *testing.T) {
+		calls = 0
+		_, err := call("k2", "")
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		_, err = call("k2", "")
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		require.Equal(t, "name is required", status.Convert(err).Message())
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("transient error not stored", func(t *testing.T) {
+		calls = 0
+		_, _ = call("k3", "flaky")
+		_, _ = call("k3", "flaky")
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		_, err := call("k1", "Gopher")
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("without key", func(t *testing.T) {
+		calls = 0
+		_, _ = call("", "World")
+		_, _ = call("", "World")
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("in flight", func(t *testing.T) {
+		store := idempotency.NewMemoryStore(time.Minute)
+		empty := sha256.Sum256(nil)
+		_, err := store.Begin(context.Background(), "/api.v1.Greeter/Greet k4", hex.EncodeToString(empty[:]))
+		require.NoError(t, err)
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyMetadata, "k4"))
+		_, err = UnaryIdempotency(slog.Default(), store)(ctx, &apiv1.GreetRequest{}, info, handler)
+		require.Equal(t, codes.Aborted, status.Code(err))
+	})
+}
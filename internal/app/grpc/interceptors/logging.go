@@ -0,0 +1,211 @@
+package interceptors
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const redacted = "[REDACTED]"
+
+// LoggingConfig configures the logging interceptors. The zero value logs
+// calls without payloads.
+type LoggingConfig struct {
+	// PayloadMethods are the full method names, e.g. "/api.v1.Greeter/Greet",
+	// whose request and response messages are logged.
+	PayloadMethods []string
+	// MaxPayloadSize truncates logged payloads to this many bytes, 0 means 1024.
+	MaxPayloadSize int
+	// RedactFields are proto field names whose values are replaced in logged
+	// payloads at any nesting level.
+	RedactFields []string
+	// CodeToLevel selects the log level of a finished call, DefaultCodeToLevel
+	// when nil.
+	CodeToLevel func(codes.Code) slog.Level
+}
+
+// DefaultCodeToLevel logs client errors at info, conditions an operator may
+// need to act on at warn and server faults at error.
+func DefaultCodeToLevel(code codes.Code) slog.Level {
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.Unauthenticated:
+		return slog.LevelInfo
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted, codes.FailedPrecondition,
+		codes.Aborted, codes.OutOfRange, codes.Unavailable:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+type logger struct {
+	l           *slog.Logger
+	payload     map[string]bool
+	maxPayload  int
+	redact      map[protoreflect.Name]bool
+	codeToLevel func(codes.Code) slog.Level
+}
+
+func newLogger(l *slog.Logger, cfg LoggingConfig) *logger {
+	lg := &logger{
+		l:           l,
+		payload:     map[string]bool{},
+		maxPayload:  cfg.MaxPayloadSize,
+		redact:      map[protoreflect.Name]bool{},
+		codeToLevel: cfg.CodeToLevel,
+	}
+	for _, m := range cfg.PayloadMethods {
+		lg.payload[m] = true
+	}
+	for _, f := range cfg.RedactFields {
+		lg.redact[protoreflect.Name(f)] = true
+	}
+	if lg.maxPayload <= 0 {
+		lg.maxPayload = 1024
+	}
+	if lg.codeToLevel == nil {
+		lg.codeToLevel = DefaultCodeToLevel
+	}
+	return lg
+}
+
+// UnaryLogging logs every unary call with its method, peer, status code and
+// duration, and the payloads of the configured methods.
+func UnaryLogging(l *slog.Logger, cfg LoggingConfig) grpc.UnaryServerInterceptor {
+	lg := newLogger(l, cfg)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		attrs := lg.callAttrs(ctx, info.FullMethod, start, err)
+		if lg.payload[info.FullMethod] {
+			attrs = append(attrs, slog.String("request", lg.format(req)))
+			if err == nil {
+				attrs = append(attrs, slog.String("response", lg.format(resp)))
+			}
+		}
+		lg.l.LogAttrs(ctx, lg.codeToLevel(status.Code(err)), "grpc request", attrs...)
+		return resp, err
+	}
+}
+
+// StreamLogging logs every stream when it ends with its method, peer, status
+// code and duration. Messages of the configured methods are logged at debug
+// level as they pass.
+func StreamLogging(l *slog.Logger, cfg LoggingConfig) grpc.StreamServerInterceptor {
+	lg := newLogger(l, cfg)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		if lg.payload[info.FullMethod] {
+			ss = &payloadStream{ServerStream: ss, lg: lg, method: info.FullMethod}
+		}
+		err := handler(srv, ss)
+
+		ctx := ss.Context()
+		lg.l.LogAttrs(ctx, lg.codeToLevel(status.Code(err)), "grpc stream", lg.callAttrs(ctx, info.FullMethod, start, err)...)
+		return err
+	}
+}
+
+func (lg *logger) callAttrs(ctx context.Context, method string, start time.Time, err error) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("code", status.Code(err).String()),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		attrs = append(attrs, slog.String("peer", p.Addr.String()))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+	}
+	return attrs
+}
+
+// format renders m as JSON with redacted fields, truncated to maxPayload.
+func (lg *logger) format(m interface{}) string {
+	pm, ok := m.(proto.Message)
+	if !ok {
+		return ""
+	}
+	if len(lg.redact) > 0 {
+		pm = proto.Clone(pm)
+		lg.redactMessage(pm.ProtoReflect())
+	}
+	b, err := protojson.Marshal(pm)
+	if err != nil {
+		return "<" + err.Error() + ">"
+	}
+	if n := lg.maxPayload; len(b) > n {
+		// do not cut a multi-byte rune in half
+		for n > 0 && !utf8.RuneStart(b[n]) {
+			n--
+		}
+		return string(b[:n]) + "...(truncated)"
+	}
+	return string(b)
+}
+
+func (lg *logger) redactMessage(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case lg.redact[fd.Name()]:
+			if fd.Kind() == protoreflect.StringKind && !fd.IsList() && !fd.IsMap() {
+				m.Set(fd, protoreflect.ValueOfString(redacted))
+			} else {
+				m.Clear(fd)
+			}
+		case fd.IsList() && fd.Message() != nil:
+			for i := 0; i < v.List().Len(); i++ {
+				lg.redactMessage(v.List().Get(i).Message())
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				lg.redactMessage(mv.Message())
+				return true
+			})
+		case fd.Message() != nil && !fd.IsMap():
+			lg.redactMessage(v.Message())
+		}
+		return true
+	})
+}
+
+type payloadStream struct {
+	grpc.ServerStream
+	lg     *logger
+	method string
+}
+
+func (s *payloadStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.log("sent", m)
+	}
+	return err
+}
+
+func (s *payloadStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.log("received", m)
+	}
+	return err
+}
+
+func (s *payloadStream) log(direction string, m interface{}) {
+	s.lg.l.LogAttrs(s.Context(), slog.LevelDebug, "grpc stream payload",
+		slog.String("method", s.method),
+		slog.String("direction", direction),
+		slog.String("payload", s.lg.format(m)),
+	)
+}
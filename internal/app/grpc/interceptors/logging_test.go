@@ -0,0 +1,80 @@
+package interceptors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apiv1 "github.com/ravilushqa/boilerplate/api/v1"
+)
+
+func TestUnaryLogging(t *testing.T) {
+	const method = "/api.v1.Greeter/Greet"
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+	ok := func(_ context.Context, req interface{}) (interface{}, error) {
+		return &apiv1.GreetResponse{Message: "Hello " + req.(*apiv1.GreetRequest).Name}, nil
+	}
+
+	call := func(cfg LoggingConfig, req *apiv1.GreetRequest, handler grpc.UnaryHandler) map[string]interface{} {
+		buf := &bytes.Buffer{}
+		l := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		_, _ = UnaryLogging(l, cfg)(context.Background(), req, info, handler)
+		entry := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		return entry
+	}
+
+	t.Run("without payload", func(t *testing.T) {
+		entry := call(LoggingConfig{}, &apiv1.GreetRequest{Name: "World"}, ok)
+		require.Equal(t, "INFO", entry["level"])
+		require.Equal(t, method, entry["method"])
+		require.Equal(t, "OK", entry["code"])
+		require.NotContains(t, entry, "request")
+	})
+
+	t.Run("payload", func(t *testing.T) {
+		entry := call(LoggingConfig{PayloadMethods: []string{method}}, &apiv1.GreetRequest{Name: "World"}, ok)
+		require.JSONEq(t, `{"name":"World"}`, entry["request"].(string))
+		require.JSONEq(t, `{"message":"Hello World"}`, entry["response"].(string))
+	})
+
+	t.Run("redacted", func(t *testing.T) {
+		req := &apiv1.GreetRequest{Name: "World"}
+		entry := call(LoggingConfig{PayloadMethods: []string{method}, RedactFields: []string{"name"}}, req, ok)
+		require.JSONEq(t, `{"name":"[REDACTED]"}`, entry["request"].(string))
+		require.Equal(t, "World", req.Name, "the request itself is not modified")
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		entry := call(LoggingConfig{PayloadMethods: []string{method}, MaxPayloadSize: 5}, &apiv1.GreetRequest{Name: "World"}, ok)
+		require.Regexp(t, `^\{\s*"na?m?\.\.\.\(truncated\)$`, entry["request"])
+	})
+
+	t.Run("truncated on a rune boundary", func(t *testing.T) {
+		// one of the consecutive sizes cuts into a two-byte rune
+		for size := 12; size < 16; size++ {
+			entry := call(LoggingConfig{PayloadMethods: []string{method}, MaxPayloadSize: size}, &apiv1.GreetRequest{Name: "éééééééé"}, ok)
+			require.Regexp(t, `^\{\s*"name":\s*"é*\.\.\.\(truncated\)$`, entry["request"])
+		}
+	})
+
+	t.Run("level by code", func(t *testing.T) {
+		fail := func(code codes.Code) grpc.UnaryHandler {
+			return func(context.Context, interface{}) (interface{}, error) {
+				return nil, status.Error(code, "failed")
+			}
+		}
+		entry := call(LoggingConfig{}, &apiv1.GreetRequest{}, fail(codes.InvalidArgument))
+		require.Equal(t, "INFO", entry["level"])
+		require.Equal(t, "failed", entry["error"])
+		require.Equal(t, "WARN", call(LoggingConfig{}, &apiv1.GreetRequest{}, fail(codes.Unavailable))["level"])
+		require.Equal(t, "ERROR", call(LoggingConfig{}, &apiv1.GreetRequest{}, fail(codes.Internal))["level"])
+	})
+}
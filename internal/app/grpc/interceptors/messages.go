@@ -0,0 +1,71 @@
+// Package interceptors contains the gRPC server interceptors, the
+// counterpart of internal/app/http/middlewares.
+package interceptors
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+var streamMsgSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "grpc_server_stream_msg_size_bytes",
+	Help:    "Size of messages sent and received on server streams.",
+	Buckets: prometheus.ExponentialBuckets(64, 4, 8),
+}, []string{"grpc_method", "direction"})
+
+func init() {
+	prometheus.MustRegister(streamMsgSize)
+}
+
+// StreamMessages logs every message sent or received on a server stream at
+// debug level and observes its size. Message counts are already exported by
+// go-grpc-prometheus as grpc_server_msg_{sent,received}_total.
+func StreamMessages(l *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &messageStream{ServerStream: ss, l: l, method: info.FullMethod})
+	}
+}
+
+type messageStream struct {
+	grpc.ServerStream
+	l        *slog.Logger
+	method   string
+	sent     int
+	received int
+}
+
+func (s *messageStream) SendMsg(m interface{}) error {
+	if err := s.ServerStream.SendMsg(m); err != nil {
+		return err
+	}
+	s.sent++
+	s.observe(m, "sent", s.sent)
+	return nil
+}
+
+func (s *messageStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	s.received++
+	s.observe(m, "received", s.received)
+	return nil
+}
+
+func (s *messageStream) observe(m interface{}, direction string, seq int) {
+	size := 0
+	if pm, ok := m.(proto.Message); ok {
+		size = proto.Size(pm)
+	}
+	streamMsgSize.WithLabelValues(s.method, direction).Observe(float64(size))
+	s.l.Log(context.Background(), slog.LevelDebug, "stream message",
+		slog.String("method", s.method),
+		slog.String("direction", direction),
+		slog.Int("seq", seq),
+		slog.Int("size", size),
+	)
+}
@@ -0,0 +1,53 @@
+package interceptors
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/ravilushqa/boilerplate/internal/apiversion"
+)
+
+// UnaryVersioning counts calls per API version and sends the deprecation
+// headers of deprecated versions as header metadata. versions are keyed by
+// full service name, e.g. "api.v1.Greeter"; other services pass through.
+func UnaryVersioning(versions map[string]apiversion.Version) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if md, ok := versionHeader(ctx, versions); ok {
+			_ = grpc.SetHeader(ctx, md)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamVersioning is the stream counterpart of UnaryVersioning.
+func StreamVersioning(versions map[string]apiversion.Version) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if md, ok := versionHeader(ss.Context(), versions); ok {
+			_ = ss.SetHeader(md)
+		}
+		return handler(srv, ss)
+	}
+}
+
+// versionHeader observes the call and returns its header metadata. The
+// method is taken from the transport rather than the server info: services
+// registered under a second name share the handlers, and with them the
+// FullMethod of the info.
+func versionHeader(ctx context.Context, versions map[string]apiversion.Version) (metadata.MD, bool) {
+	method, _ := grpc.Method(ctx)
+	service, _, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	v, ok := versions[service]
+	if !ok {
+		return nil, false
+	}
+	apiversion.Observe("grpc", v.Name, apiversion.SelectedByPath)
+
+	md := metadata.Pairs(strings.ToLower(apiversion.Header), v.Name)
+	for k, vals := range v.DeprecationHeaders() {
+		md.Append(strings.ToLower(k), vals...)
+	}
+	return md, true
+}
@@ -0,0 +1,127 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+
+	"github.com/ravilushqa/boilerplate/internal/app/grpc/interceptors"
+	"github.com/ravilushqa/boilerplate/internal/idempotency"
+	"github.com/ravilushqa/boilerplate/internal/operations"
+	"github.com/ravilushqa/boilerplate/internal/recovery"
+)
+
+// Option configures the gRPC server transport. Without options the
+// grpc-go defaults apply.
+type Option interface {
+	apply(*Server)
+}
+
+type optionFunc func(*Server)
+
+func (o optionFunc) apply(s *Server) {
+	o(s)
+}
+
+// WithKeepalive sets keepalive and connection age parameters. A finite
+// MaxConnectionAge makes clients reconnect periodically, so long-lived
+// connections get rebalanced across pods behind a load balancer.
+func WithKeepalive(p keepalive.ServerParameters) Option {
+	return optionFunc(func(s *Server) {
+		s.serverOpts = append(s.serverOpts, grpc.KeepaliveParams(p))
+	})
+}
+
+// WithKeepaliveEnforcement sets the policy for client pings. Clients pinging
+// more often than MinTime get their connection closed with GOAWAY.
+func WithKeepaliveEnforcement(p keepalive.EnforcementPolicy) Option {
+	return optionFunc(func(s *Server) {
+		s.serverOpts = append(s.serverOpts, grpc.KeepaliveEnforcementPolicy(p))
+	})
+}
+
+// WithMaxMsgSize sets the max receive and send message sizes in bytes.
+// Zero keeps the grpc-go default.
+func WithMaxMsgSize(recv, send int) Option {
+	return optionFunc(func(s *Server) {
+		if recv > 0 {
+			s.serverOpts = append(s.serverOpts, grpc.MaxRecvMsgSize(recv))
+		}
+		if send > 0 {
+			s.serverOpts = append(s.serverOpts, grpc.MaxSendMsgSize(send))
+		}
+	})
+}
+
+// WithMaxConcurrentStreams limits the concurrent streams of each connection.
+// Zero keeps the grpc-go default.
+func WithMaxConcurrentStreams(n uint32) Option {
+	return optionFunc(func(s *Server) {
+		if n > 0 {
+			s.serverOpts = append(s.serverOpts, grpc.MaxConcurrentStreams(n))
+		}
+	})
+}
+
+// WithConnectionTimeout sets the timeout for connection establishment,
+// including the HTTP/2 handshake. Zero keeps the grpc-go default.
+func WithConnectionTimeout(d time.Duration) Option {
+	return optionFunc(func(s *Server) {
+		if d > 0 {
+			s.serverOpts = append(s.serverOpts, grpc.ConnectionTimeout(d))
+		}
+	})
+}
+
+// WithHealthCheck drives the grpc.health.v1 status of the server from check,
+// polled every interval: SERVING while it passes, NOT_SERVING otherwise.
+// Without it the server reports SERVING until it starts draining.
+func WithHealthCheck(check func(context.Context) error, interval time.Duration) Option {
+	return optionFunc(func(s *Server) {
+		s.healthCheck = check
+		s.healthInterval = interval
+	})
+}
+
+// WithChannelz registers the channelz service for connection debugging,
+// e.g. with grpcdebug.
+func WithChannelz() Option {
+	return optionFunc(func(s *Server) {
+		s.channelz = true
+	})
+}
+
+// WithLogging configures request logging, e.g. payload logging for some
+// methods. Calls are logged without payloads by default.
+func WithLogging(cfg interceptors.LoggingConfig) Option {
+	return optionFunc(func(s *Server) {
+		s.logging = cfg
+	})
+}
+
+// WithRecovery sets the handler of panics recovered in RPC handlers.
+// By default panics are only logged.
+func WithRecovery(h *recovery.Handler) Option {
+	return optionFunc(func(s *Server) {
+		s.recovery = h
+	})
+}
+
+// WithIdempotency replays stored responses of unary calls sent again with
+// the same idempotency-key metadata. Without it the key is ignored.
+func WithIdempotency(store idempotency.Store) Option {
+	return optionFunc(func(s *Server) {
+		s.idempotency = store
+	})
+}
+
+// WithOperations registers the google.longrunning.Operations service, which
+// clients use to follow the operations started by other RPCs, e.g.
+// GreetLater. Without it GreetLater is UNIMPLEMENTED.
+func WithOperations(m *operations.Manager) Option {
+	return optionFunc(func(s *Server) {
+		s.operations = m
+	})
+}
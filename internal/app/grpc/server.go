@@ -2,28 +2,77 @@ package grpc
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log/slog"
 	"net"
+	"time"
 
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
 	grpcmiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpcrecovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
 	grpcprometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	channelzsvc "google.golang.org/grpc/channelz/service"
 	"google.golang.org/grpc/codes"
+	// registered compressors answer clients calling with the same grpc-encoding
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
-	"github.com/ravilushqa/boilerplate/api"
+	apiv1 "github.com/ravilushqa/boilerplate/api/v1"
+	"github.com/ravilushqa/boilerplate/internal/apiversion"
+	"github.com/ravilushqa/boilerplate/internal/app/grpc/interceptors"
+	"github.com/ravilushqa/boilerplate/internal/idempotency"
+	"github.com/ravilushqa/boilerplate/internal/listing"
+	"github.com/ravilushqa/boilerplate/internal/operations"
+	"github.com/ravilushqa/boilerplate/internal/recovery"
+	"github.com/ravilushqa/boilerplate/internal/service"
+	_ "github.com/ravilushqa/boilerplate/pkg/encoding/zstd"
 )
 
+// legacyGreeterService is the name of the Greeter service before the API was
+// versioned. Its clients are served by the api.v1 implementation, whose
+// messages are wire compatible, until they have moved. Reflection does not
+// describe it.
+const legacyGreeterService = "api.Greeter"
+
+// versions are the served versions of the API by full service name.
+var versions = map[string]apiversion.Version{
+	apiv1.Greeter_ServiceDesc.ServiceName: {Name: "v1"},
+	legacyGreeterService:                  {Name: "v0", Deprecated: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)},
+}
+
 type Server struct {
-	api.GreeterServer
-	l    *slog.Logger
-	addr string
+	apiv1.GreeterServer
+	l       *slog.Logger
+	addr    string
+	greeter service.Greeter
+
+	serverOpts     []grpc.ServerOption
+	logging        interceptors.LoggingConfig
+	recovery       *recovery.Handler
+	idempotency    idempotency.Store
+	operations     *operations.Manager
+	healthCheck    func(context.Context) error
+	healthInterval time.Duration
+	channelz       bool
 }
 
-func New(l *slog.Logger, addr string) *Server {
-	return &Server{l: l, addr: addr}
+func New(l *slog.Logger, addr string, greeter service.Greeter, opts ...Option) *Server {
+	s := &Server{l: l, addr: addr, greeter: greeter}
+	for _, opt := range opts {
+		opt.apply(s)
+	}
+	if s.recovery == nil {
+		s.recovery = recovery.New(l, nil)
+	}
+	return s
 }
 
 func (s *Server) Run(ctx context.Context) error {
@@ -32,38 +81,255 @@ func (s *Server) Run(ctx context.Context) error {
 		return err
 	}
 
-	grpcSrv := grpc.NewServer(
+	recoveryOpt := grpcrecovery.WithRecoveryHandlerContext(s.recoveryHandler)
+	unary := []grpc.UnaryServerInterceptor{
+		grpcprometheus.UnaryServerInterceptor,
+		interceptors.UnaryVersioning(versions),
+		interceptors.UnaryLogging(s.l, s.logging),
+		grpcrecovery.UnaryServerInterceptor(recoveryOpt),
+	}
+	if s.idempotency != nil {
+		unary = append(unary, interceptors.UnaryIdempotency(s.l, s.idempotency))
+	}
+	grpcSrv := grpc.NewServer(append([]grpc.ServerOption{
 		grpc.StreamInterceptor(grpcmiddleware.ChainStreamServer(
 			grpcprometheus.StreamServerInterceptor,
-			grpcrecovery.StreamServerInterceptor(),
+			interceptors.StreamVersioning(versions),
+			interceptors.StreamLogging(s.l, s.logging),
+			grpcrecovery.StreamServerInterceptor(recoveryOpt),
+			interceptors.StreamMessages(s.l),
 		)),
-		grpc.UnaryInterceptor(grpcmiddleware.ChainUnaryServer(
-			grpcprometheus.UnaryServerInterceptor,
-			grpcrecovery.UnaryServerInterceptor(),
-		)),
-	)
+		grpc.UnaryInterceptor(grpcmiddleware.ChainUnaryServer(unary...)),
+	}, s.serverOpts...)...)
 	grpcprometheus.EnableHandlingTimeHistogram()
 
-	api.RegisterGreeterServer(grpcSrv, s)
+	apiv1.RegisterGreeterServer(grpcSrv, s)
+	legacy := apiv1.Greeter_ServiceDesc
+	legacy.ServiceName = legacyGreeterService
+	grpcSrv.RegisterService(&legacy, s)
+	if s.operations != nil {
+		longrunningpb.RegisterOperationsServer(grpcSrv, s.operations)
+	}
+
+	healthSrv := health.NewServer()
+	healthpb.RegisterHealthServer(grpcSrv, healthSrv)
+	if s.healthCheck != nil {
+		s.setServingStatus(healthSrv, healthpb.HealthCheckResponse_NOT_SERVING)
+		go s.watchHealth(ctx, healthSrv)
+	} else {
+		s.setServingStatus(healthSrv, healthpb.HealthCheckResponse_SERVING)
+	}
 
 	reflection.Register(grpcSrv)
+	if s.channelz {
+		channelzsvc.RegisterChannelzServiceToServer(grpcSrv)
+	}
 
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		<-ctx.Done()
-		grpcSrv.GracefulStop()
 		s.l.Info("[GRPC] server stopping", slog.String("addr", s.addr))
+		// report NOT_SERVING to health watchers before draining
+		healthSrv.Shutdown()
+		grpcSrv.GracefulStop()
 	}()
 
 	s.l.Info("[GRPC] server listening", slog.String("addr", s.addr))
 
-	return grpcSrv.Serve(lis)
+	if err = grpcSrv.Serve(lis); err != nil {
+		return err
+	}
+	// Serve returns as soon as the listener closes, wait for in-flight RPCs
+	<-stopped
+	return nil
+}
+
+// recoveryHandler converts a panic into an Internal status that carries only
+// the correlation ID under which the panic was logged and reported.
+func (s *Server) recoveryHandler(ctx context.Context, p interface{}) error {
+	method, _ := grpc.Method(ctx)
+	id := s.recovery.Handle(ctx, p, "grpc", map[string]string{"method": method})
+	st, err := status.New(codes.Internal, "internal error, correlation id "+id).
+		WithDetails(&errdetails.RequestInfo{RequestId: id})
+	if err != nil {
+		return status.Error(codes.Internal, "internal error, correlation id "+id)
+	}
+	return st.Err()
 }
 
-func (s *Server) Greet(_ context.Context, r *api.GreetRequest) (*api.GreetResponse, error) {
-	if r.Name == "" {
-		return nil, status.Error(codes.InvalidArgument, "name cannot be empty")
+// watchHealth polls the health check until ctx is done. Once the server
+// drains, healthSrv.Shutdown makes further updates no-ops.
+func (s *Server) watchHealth(ctx context.Context, healthSrv *health.Server) {
+	t := time.NewTicker(s.healthInterval)
+	defer t.Stop()
+	for {
+		status := healthpb.HealthCheckResponse_SERVING
+		if err := s.healthCheck(ctx); err != nil {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		s.setServingStatus(healthSrv, status)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
 	}
-	return &api.GreetResponse{
-		Message: "Hello " + r.Name,
+}
+
+// setServingStatus sets the overall server status and the status of each
+// registered service.
+func (s *Server) setServingStatus(healthSrv *health.Server, status healthpb.HealthCheckResponse_ServingStatus) {
+	healthSrv.SetServingStatus("", status)
+	for service := range versions {
+		healthSrv.SetServingStatus(service, status)
+	}
+}
+
+func (s *Server) Greet(ctx context.Context, r *apiv1.GreetRequest) (*apiv1.GreetResponse, error) {
+	greeting, err := s.greeter.Greet(ctx, r.Name)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &apiv1.GreetResponse{
+		Message: greeting,
 	}, nil
 }
+
+const (
+	maxStreamCount    = 100
+	maxStreamInterval = 10 * time.Second
+)
+
+// GreetStream sends r.Count greetings. Send blocks while the client's flow
+// control window is full, which provides backpressure, and the wait between
+// greetings ends early when the client cancels.
+func (s *Server) GreetStream(r *apiv1.GreetStreamRequest, stream apiv1.Greeter_GreetStreamServer) error {
+	ctx := stream.Context()
+	if r.Count == 0 || r.Count > maxStreamCount {
+		return status.Errorf(codes.InvalidArgument, "count must be between 1 and %d", maxStreamCount)
+	}
+	interval := time.Duration(r.IntervalMs) * time.Millisecond
+	if interval > maxStreamInterval {
+		return status.Errorf(codes.InvalidArgument, "interval must be at most %s", maxStreamInterval)
+	}
+
+	greeting, err := s.greeter.Greet(ctx, r.Name)
+	if err != nil {
+		return s.toStatus(err)
+	}
+
+	t := time.NewTimer(0)
+	defer t.Stop()
+	for i := uint32(0); i < r.Count; i++ {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-t.C:
+		}
+		if err = stream.Send(&apiv1.GreetResponse{Message: greeting}); err != nil {
+			return err
+		}
+		t.Reset(interval)
+	}
+	return nil
+}
+
+// Chat replies to every request with a greeting until the client closes its
+// side of the stream. The next request is read only after the reply is sent.
+func (s *Server) Chat(stream apiv1.Greeter_ChatServer) error {
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		greeting, err := s.greeter.Greet(stream.Context(), r.Name)
+		if err != nil {
+			return s.toStatus(err)
+		}
+		if err = stream.Send(&apiv1.GreetResponse{Message: greeting}); err != nil {
+			return err
+		}
+	}
+}
+
+// ListGreetings lists the recent greetings with the listing conventions.
+func (s *Server) ListGreetings(ctx context.Context, r *apiv1.ListGreetingsRequest) (*apiv1.ListGreetingsResponse, error) {
+	greetings, next, err := s.greeter.ListGreetings(ctx, listing.Request{
+		PageSize:  int(r.PageSize),
+		PageToken: r.PageToken,
+		Filter:    r.Filter,
+		OrderBy:   r.OrderBy,
+	})
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	resp := &apiv1.ListGreetingsResponse{NextPageToken: next}
+	for _, g := range greetings {
+		resp.Greetings = append(resp.Greetings, &apiv1.Greeting{
+			Id:         g.ID,
+			Name:       g.Name,
+			Message:    g.Message,
+			CreateTime: timestamppb.New(g.CreateTime),
+		})
+	}
+	return resp, nil
+}
+
+// maxGreetDelay bounds the delay of GreetLater.
+const maxGreetDelay = time.Minute
+
+// GreetLater starts a long-running operation that greets after r.DelayMs and
+// returns it. The greeting runs detached from the call, the client follows
+// it with the Operations service.
+func (s *Server) GreetLater(ctx context.Context, r *apiv1.GreetLaterRequest) (*longrunningpb.Operation, error) {
+	if s.operations == nil {
+		return nil, status.Error(codes.Unimplemented, "operations are not served")
+	}
+	delay := time.Duration(r.DelayMs) * time.Millisecond
+	if delay > maxGreetDelay {
+		return nil, status.Errorf(codes.InvalidArgument, "delay must be at most %s", maxGreetDelay)
+	}
+
+	op, err := s.operations.Start(ctx, nil, func(ctx context.Context, _ func(proto.Message) error) (proto.Message, error) {
+		t := time.NewTimer(delay)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+		greeting, err := s.greeter.Greet(ctx, r.Name)
+		if err != nil {
+			return nil, s.toStatus(err)
+		}
+		return &apiv1.GreetResponse{Message: greeting}, nil
+	})
+	if errors.Is(err, operations.ErrClosed) {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return op, nil
+}
+
+// toStatus maps a service error to a gRPC status. Messages of internal
+// errors are logged and not exposed to the client.
+func (s *Server) toStatus(err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidArgument):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrConflict):
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	s.l.Error("[GRPC] request failed", slog.Any("error", err))
+	return status.Error(codes.Internal, codes.Internal.String())
+}
@@ -2,15 +2,26 @@ package grpc
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log/slog"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
-	"github.com/ravilushqa/boilerplate/api"
+	apiv1 "github.com/ravilushqa/boilerplate/api/v1"
+	"github.com/ravilushqa/boilerplate/internal/operations"
+	"github.com/ravilushqa/boilerplate/internal/service"
 )
 
 const (
@@ -18,7 +29,8 @@ const (
 )
 
 func TestServer(t *testing.T) {
-	s := New(slog.Default(), addr)
+	ops := operations.NewManager(slog.Default(), operations.NewMemoryStore(time.Hour))
+	s := New(slog.Default(), addr, service.NewGreeter(), WithOperations(ops))
 	ctx, cancel := context.WithCancel(context.Background())
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
@@ -38,10 +50,161 @@ func TestServer(t *testing.T) {
 		require.NoError(t, err)
 		defer cc.Close()
 
-		c := api.NewGreeterClient(cc)
-		resp, err := c.Greet(ctx, &api.GreetRequest{Name: "World"})
+		c := apiv1.NewGreeterClient(cc)
+		resp, err := c.Greet(ctx, &apiv1.GreetRequest{Name: "World"})
 		require.NoError(t, err)
 
 		require.Equal(t, "Hello World", resp.Message)
 	})
+
+	t.Run("list greetings", func(t *testing.T) {
+		cc, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer cc.Close()
+		c := apiv1.NewGreeterClient(cc)
+
+		resp, err := c.ListGreetings(ctx, &apiv1.ListGreetingsRequest{PageSize: 1, Filter: `name = "World"`})
+		require.NoError(t, err)
+		require.Len(t, resp.Greetings, 1)
+		require.Equal(t, "Hello World", resp.Greetings[0].Message)
+
+		_, err = c.ListGreetings(ctx, &apiv1.ListGreetingsRequest{OrderBy: "age"})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("operations", func(t *testing.T) {
+		cc, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer cc.Close()
+		c := longrunningpb.NewOperationsClient(cc)
+
+		op, err := apiv1.NewGreeterClient(cc).GreetLater(ctx, &apiv1.GreetLaterRequest{Name: "World", DelayMs: 10})
+		require.NoError(t, err)
+		got, err := c.WaitOperation(ctx, &longrunningpb.WaitOperationRequest{Name: op.Name})
+		require.NoError(t, err)
+		require.True(t, got.Done)
+		resp := &apiv1.GreetResponse{}
+		require.NoError(t, got.GetResponse().UnmarshalTo(resp))
+		require.Equal(t, "Hello World", resp.Message)
+
+		_, err = c.GetOperation(ctx, &longrunningpb.GetOperationRequest{Name: "operations/unknown"})
+		require.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("greet stream", func(t *testing.T) {
+		cc, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer cc.Close()
+		c := apiv1.NewGreeterClient(cc)
+
+		stream, err := c.GreetStream(ctx, &apiv1.GreetStreamRequest{Name: "World", Count: 3, IntervalMs: 10})
+		require.NoError(t, err)
+		var got []string
+		for {
+			resp, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			got = append(got, resp.Message)
+		}
+		require.Equal(t, []string{"Hello World", "Hello World", "Hello World"}, got)
+
+		stream, err = c.GreetStream(ctx, &apiv1.GreetStreamRequest{Name: "World"})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		streamCtx, streamCancel := context.WithCancel(ctx)
+		stream, err = c.GreetStream(streamCtx, &apiv1.GreetStreamRequest{Name: "World", Count: 2, IntervalMs: 10000})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.NoError(t, err)
+		streamCancel()
+		_, err = stream.Recv()
+		require.Equal(t, codes.Canceled, status.Code(err))
+	})
+
+	t.Run("chat", func(t *testing.T) {
+		cc, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer cc.Close()
+
+		stream, err := apiv1.NewGreeterClient(cc).Chat(ctx)
+		require.NoError(t, err)
+		for _, name := range []string{"Alice", "Bob"} {
+			require.NoError(t, stream.Send(&apiv1.GreetRequest{Name: name}))
+			resp, err := stream.Recv()
+			require.NoError(t, err)
+			require.Equal(t, "Hello "+name, resp.Message)
+		}
+		require.NoError(t, stream.CloseSend())
+		_, err = stream.Recv()
+		require.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("legacy service", func(t *testing.T) {
+		cc, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer cc.Close()
+
+		var header metadata.MD
+		resp := &apiv1.GreetResponse{}
+		err = cc.Invoke(ctx, "/api.Greeter/Greet", &apiv1.GreetRequest{Name: "World"}, resp, grpc.Header(&header))
+		require.NoError(t, err)
+		require.Equal(t, "Hello World", resp.Message)
+		require.Equal(t, []string{"v0"}, header.Get("api-version"))
+		require.NotEmpty(t, header.Get("deprecation"))
+
+		_, err = apiv1.NewGreeterClient(cc).Greet(ctx, &apiv1.GreetRequest{Name: "World"}, grpc.Header(&header))
+		require.NoError(t, err)
+		require.Equal(t, []string{"v1"}, header.Get("api-version"))
+		require.Empty(t, header.Get("deprecation"))
+	})
+
+	t.Run("health", func(t *testing.T) {
+		cc, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer cc.Close()
+
+		c := healthpb.NewHealthClient(cc)
+		for _, svc := range []string{"", apiv1.Greeter_ServiceDesc.ServiceName, legacyGreeterService} {
+			resp, err := c.Check(ctx, &healthpb.HealthCheckRequest{Service: svc}, grpc.WaitForReady(true))
+			require.NoError(t, err)
+			require.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status, svc)
+		}
+	})
+}
+
+func TestServer_options(t *testing.T) {
+	const optsAddr = ":50052"
+	s := New(slog.Default(), optsAddr, service.NewGreeter(),
+		WithMaxMsgSize(1024, 0),
+		WithHealthCheck(func(context.Context) error { return errors.New("draining") }, time.Second),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := s.Run(ctx)
+		require.NoError(t, err)
+	}()
+
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	cc, err := grpc.Dial(optsAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer cc.Close()
+
+	c := apiv1.NewGreeterClient(cc)
+	_, err = c.Greet(ctx, &apiv1.GreetRequest{Name: strings.Repeat("a", 2048)}, grpc.WaitForReady(true))
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	resp, err := healthpb.NewHealthClient(cc).Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
 }
@@ -4,16 +4,21 @@ import (
 	"context"
 	"log/slog"
 	"testing"
+	"time"
 
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
-	"github.com/ravilushqa/boilerplate/api"
+	apiv1 "github.com/ravilushqa/boilerplate/api/v1"
+	"github.com/ravilushqa/boilerplate/internal/operations"
+	"github.com/ravilushqa/boilerplate/internal/service"
 )
 
 func TestServer_Greet(t *testing.T) {
-	s := New(slog.Default(), addr)
+	s := New(slog.Default(), addr, service.NewGreeter())
 
 	// set up test cases
 	tests := []struct {
@@ -27,12 +32,12 @@ func TestServer_Greet(t *testing.T) {
 		},
 		{
 			name: "",
-			err:  status.Error(codes.InvalidArgument, "name cannot be empty"),
+			err:  status.Error(codes.InvalidArgument, "name is required"),
 		},
 	}
 
 	for _, tt := range tests {
-		req := &api.GreetRequest{Name: tt.name}
+		req := &apiv1.GreetRequest{Name: tt.name}
 		resp, err := s.Greet(context.Background(), req)
 		require.Equal(t, tt.err, err)
 
@@ -41,3 +46,47 @@ func TestServer_Greet(t *testing.T) {
 		}
 	}
 }
+
+func TestServer_GreetLater(t *testing.T) {
+	ctx := context.Background()
+	_, err := New(slog.Default(), addr, service.NewGreeter()).GreetLater(ctx, &apiv1.GreetLaterRequest{Name: "World"})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+
+	ops := operations.NewManager(slog.Default(), operations.NewMemoryStore(time.Hour))
+	s := New(slog.Default(), addr, service.NewGreeter(), WithOperations(ops))
+	_, err = s.GreetLater(ctx, &apiv1.GreetLaterRequest{Name: "World", DelayMs: 61000})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	wait := func(req *apiv1.GreetLaterRequest) *longrunningpb.Operation {
+		op, err := s.GreetLater(ctx, req)
+		require.NoError(t, err)
+		require.False(t, op.Done)
+		op, err = ops.WaitOperation(ctx, &longrunningpb.WaitOperationRequest{Name: op.Name})
+		require.NoError(t, err)
+		require.True(t, op.Done)
+		return op
+	}
+
+	op := wait(&apiv1.GreetLaterRequest{Name: "World", DelayMs: 10})
+	resp := &apiv1.GreetResponse{}
+	require.NoError(t, op.GetResponse().UnmarshalTo(resp))
+	require.Equal(t, "Hello World", resp.Message)
+
+	op = wait(&apiv1.GreetLaterRequest{})
+	require.Equal(t, int32(codes.InvalidArgument), op.GetError().GetCode())
+	require.Equal(t, "name is required", op.GetError().GetMessage())
+}
+
+func TestServer_recoveryHandler(t *testing.T) {
+	s := New(slog.Default(), addr, service.NewGreeter())
+
+	err := s.recoveryHandler(context.Background(), "boom")
+	st := status.Convert(err)
+	require.Equal(t, codes.Internal, st.Code())
+	require.NotContains(t, st.Message(), "boom")
+
+	require.Len(t, st.Details(), 1)
+	info, ok := st.Details()[0].(*errdetails.RequestInfo)
+	require.True(t, ok)
+	require.Contains(t, st.Message(), info.RequestId)
+}
@@ -0,0 +1,196 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ravilushqa/boilerplate/internal/cache"
+)
+
+var (
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_response_cache_lookups_total",
+		Help: "Number of response cache lookups by route and result (hit or miss).",
+	}, []string{"route", "result"})
+	cacheEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "http_response_cache_evictions_total",
+		Help: "Number of responses evicted from the response cache to make room.",
+	})
+	notModified = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_not_modified_total",
+		Help: "Number of conditional requests answered with 304 Not Modified.",
+	}, []string{"route"})
+)
+
+func init() {
+	prometheus.MustRegister(cacheLookups, cacheEvictions, notModified)
+}
+
+// CachePolicy configures caching of a GET route.
+type CachePolicy struct {
+	// Route labels the metrics of the route.
+	Route string
+	// CacheControl is set on successful responses that have none,
+	// e.g. "public, max-age=60".
+	CacheControl string
+	// TTL keeps successful responses in the ResponseCache, 0 disables it for
+	// the route.
+	TTL time.Duration
+}
+
+type cachedResponse struct {
+	header http.Header
+	body   []byte
+}
+
+// ResponseCache is an in-process LRU cache of successful GET responses keyed
+// by URL. Responses are shared by all callers, so routes whose responses
+// depend on who is asking must not use it.
+type ResponseCache struct {
+	lru *cache.LRU[string, *cachedResponse]
+}
+
+// NewResponseCache returns a cache holding up to size responses.
+func NewResponseCache(size int) *ResponseCache {
+	return &ResponseCache{lru: cache.New[string, *cachedResponse](size)}
+}
+
+// NewCaching adds a strong ETag to successful GET and HEAD responses and
+// answers If-None-Match and If-Modified-Since with 304 Not Modified. rc may be
+// nil, then responses are still computed on every request and only the
+// transfer is saved. Responses are buffered, so streaming routes must not
+// use it.
+func NewCaching(policy CachePolicy, rc *ResponseCache) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			useCache := rc != nil && policy.TTL > 0
+			key := r.Method + " " + r.URL.RequestURI()
+			if useCache {
+				if c, ok := rc.lru.Get(key); ok {
+					cacheLookups.WithLabelValues(policy.Route, "hit").Inc()
+					serveCached(w, r, policy, c.header, http.StatusOK, c.body)
+					return
+				}
+				cacheLookups.WithLabelValues(policy.Route, "miss").Inc()
+			}
+
+			buf := &bufferingWriter{header: http.Header{}}
+			next.ServeHTTP(buf, r)
+			status := buf.status()
+			if status != http.StatusOK {
+				serveCached(w, r, policy, buf.header, status, buf.body.Bytes())
+				return
+			}
+
+			h := buf.header
+			if h.Get("ETag") == "" {
+				sum := sha256.Sum256(buf.body.Bytes())
+				h.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+			}
+			if h.Get("Cache-Control") == "" && policy.CacheControl != "" {
+				h.Set("Cache-Control", policy.CacheControl)
+			}
+			if useCache {
+				if h.Get("Last-Modified") == "" {
+					h.Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+				}
+				if rc.lru.Set(key, &cachedResponse{header: h.Clone(), body: buf.body.Bytes()}, policy.TTL) {
+					cacheEvictions.Inc()
+				}
+			}
+			serveCached(w, r, policy, h, status, buf.body.Bytes())
+		})
+	}
+}
+
+func serveCached(w http.ResponseWriter, r *http.Request, policy CachePolicy, h http.Header, status int, body []byte) {
+	for k, v := range h {
+		w.Header()[k] = v
+	}
+	if status == http.StatusOK && isNotModified(r, h) {
+		notModified.WithLabelValues(policy.Route).Inc()
+		// a 304 carries the validators and caching headers but no content
+		for _, k := range []string{"Content-Type", "Content-Length"} {
+			w.Header().Del(k)
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// isNotModified evaluates the conditional headers as RFC 9110 13.2.2 orders
+// them: If-Modified-Since is ignored when If-None-Match is present.
+func isNotModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatch(inm, h.Get("ETag"))
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lm, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !lm.After(ims)
+}
+
+// etagMatch uses the weak comparison If-None-Match requires.
+func etagMatch(header, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferingWriter holds the response until the handler returns.
+type bufferingWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (w *bufferingWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferingWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *bufferingWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *bufferingWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
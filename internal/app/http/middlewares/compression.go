@@ -0,0 +1,259 @@
+package middlewares
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/mux"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionConfig configures response compression.
+type CompressionConfig struct {
+	// MinSize is the smallest response compressed, 0 means 1024 bytes.
+	// Smaller bodies gain little and cost CPU on both sides.
+	MinSize int
+	// ContentTypes are the compressed media types. A trailing "*" matches
+	// any subtype, e.g. "text/*". DefaultCompressibleTypes when empty.
+	ContentTypes []string
+}
+
+// DefaultCompressibleTypes are textual types. Images, archives and other
+// already compressed types are left out on purpose.
+var DefaultCompressibleTypes = []string{
+	"text/*",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/problem+json",
+	"application/x-ndjson",
+	"image/svg+xml",
+}
+
+// encoder is implemented by the gzip, zstd and brotli writers.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// encodings in server preference order, used when the client gives several
+// the same weight.
+var encodings = []struct {
+	name string
+	pool *sync.Pool
+}{
+	{"zstd", &sync.Pool{New: func() any {
+		e, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return e
+	}}},
+	{"br", &sync.Pool{New: func() any { return brotli.NewWriterLevel(nil, 4) }}},
+	{"gzip", &sync.Pool{New: func() any {
+		e, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return e
+	}}},
+}
+
+// NewCompression compresses responses with the zstd, brotli or gzip encoding
+// the client accepts. Bodies below the configured minimum size, types that
+// are not compressible, responses that already have a Content-Encoding and
+// event streams are sent as they are. A strong ETag is weakened on compressed
+// responses, since it no longer matches the identity representation.
+func NewCompression(cfg CompressionConfig) mux.MiddlewareFunc {
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = 1024
+	}
+	if len(cfg.ContentTypes) == 0 {
+		cfg.ContentTypes = DefaultCompressibleTypes
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead || r.Header.Get("Range") != "" || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Add("Vary", "Accept-Encoding")
+			enc := negotiate(r.Header.Get("Accept-Encoding"))
+			if enc < 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, cfg: &cfg, enc: enc}
+			next.ServeHTTP(cw, r)
+			_ = cw.Close()
+		})
+	}
+}
+
+// negotiate returns the index in encodings of the accepted encoding with the
+// highest weight, or -1.
+func negotiate(header string) int {
+	if header == "" {
+		return -1
+	}
+	weights := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		weights[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := -1, 0.0
+	for i, e := range encodings {
+		q, ok := weights[e.name]
+		if !ok {
+			q, ok = weights["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the start of the body until it knows whether the
+// response is worth compressing: MinSize bytes were written, the handler
+// returned or flushed.
+type compressWriter struct {
+	http.ResponseWriter
+	cfg     *CompressionConfig
+	enc     int
+	code    int
+	buf     []byte
+	decided bool
+	encoder encoder
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.code != 0 {
+		return
+	}
+	w.code = code
+	// bodiless and informational responses go out right away
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		w.decided = true
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.cfg.MinSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends what is buffered, uncompressed when the decision is still
+// open: a flushing handler streams and must not wait for MinSize bytes.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide(false)
+	}
+	if w.encoder != nil {
+		_ = w.encoder.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		if w.code == 0 && len(w.buf) == 0 {
+			return nil
+		}
+		if err := w.decide(len(w.buf) >= w.cfg.MinSize); err != nil {
+			return err
+		}
+	}
+	if w.encoder == nil {
+		return nil
+	}
+	err := w.encoder.Close()
+	w.encoder.Reset(nil)
+	encodings[w.enc].pool.Put(w.encoder)
+	w.encoder = nil
+	return err
+}
+
+func (w *compressWriter) decide(bigEnough bool) error {
+	w.decided = true
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		// set it before compressing, net/http would sniff compressed bytes
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+
+	if bigEnough && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type"), w.cfg.ContentTypes) {
+		h.Set("Content-Encoding", encodings[w.enc].name)
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		w.encoder = encodings[w.enc].pool.Get().(encoder)
+		w.encoder.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.code)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func compressible(contentType string, types []string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mt == "text/event-stream" {
+		return false
+	}
+	for _, t := range types {
+		if prefix, ok := strings.CutSuffix(t, "*"); ok {
+			if strings.HasPrefix(mt, prefix) {
+				return true
+			}
+		} else if mt == t {
+			return true
+		}
+	}
+	return false
+}
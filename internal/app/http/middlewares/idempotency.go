@@ -0,0 +1,174 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ravilushqa/boilerplate/internal/idempotency"
+)
+
+const (
+	// IdempotencyKeyHeader carries the key chosen by the client.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on replayed responses.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxStoredBody = 1 << 20
+	// maxRequestBody bounds the body of a request with a key, which is read
+	// at once to be hashed.
+	maxRequestBody = 1 << 20
+)
+
+// NewIdempotency replays the stored response of a POST or PATCH request sent
+// again with the same Idempotency-Key header. A duplicate arriving while the
+// first request is still handled gets 409, a key reused with another body
+// gets 422. Server errors and responses that may change on retry (401, 403,
+// 408, 429) are not stored, so the request can be retried with the same key.
+// A request with a key and a body over 1 MiB gets 413.
+func NewIdempotency(l *slog.Logger, store idempotency.Store) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodPatch) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > idempotency.MaxKeyLength {
+				respondIdempotencyError(w, http.StatusBadRequest, "idempotency key is too long")
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+			var tooLarge *http.MaxBytesError
+			switch {
+			case errors.As(err, &tooLarge):
+				respondIdempotencyError(w, http.StatusRequestEntityTooLarge, "request body is too large")
+				return
+			case err != nil:
+				respondIdempotencyError(w, http.StatusBadRequest, "failed to read request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			sum := sha256.Sum256(body)
+
+			ctx := r.Context()
+			scoped := r.Method + " " + r.URL.Path + " " + key
+			stored, err := store.Begin(ctx, scoped, hex.EncodeToString(sum[:]))
+			switch {
+			case errors.Is(err, idempotency.ErrInFlight):
+				respondIdempotencyError(w, http.StatusConflict, err.Error())
+				return
+			case errors.Is(err, idempotency.ErrMismatch):
+				respondIdempotencyError(w, http.StatusUnprocessableEntity, err.Error())
+				return
+			case err != nil:
+				l.Error("[HTTP] idempotency store failed", slog.Any("error", err))
+				respondIdempotencyError(w, http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable))
+				return
+			case stored != nil:
+				for k, v := range stored.Header {
+					w.Header()[k] = v
+				}
+				w.Header().Set(IdempotentReplayedHeader, "true")
+				w.WriteHeader(stored.Status)
+				_, _ = w.Write(stored.Body)
+				return
+			}
+
+			rec := &recordingWriter{ResponseWriter: w}
+			completed := false
+			// released on panics too, the recovery middleware sits outside
+			defer func() {
+				if completed {
+					return
+				}
+				if err := store.Release(ctx, scoped); err != nil {
+					l.Error("[HTTP] idempotency release failed", slog.Any("error", err))
+				}
+			}()
+			next.ServeHTTP(rec, r)
+
+			if !cacheable(rec.status()) || rec.overflow {
+				return
+			}
+			err = store.Complete(ctx, scoped, &idempotency.Response{Status: rec.status(), Header: rec.storedHeader(), Body: rec.body.Bytes()})
+			if err != nil {
+				l.Error("[HTTP] idempotency store failed", slog.Any("error", err))
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+func cacheable(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return status < http.StatusInternalServerError
+}
+
+func respondIdempotencyError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{Error: msg})
+}
+
+// recordingWriter copies the response it writes through.
+type recordingWriter struct {
+	http.ResponseWriter
+	code     int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *recordingWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+		w.header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.body.Len()+len(b) > maxStoredBody {
+		w.overflow = true
+	} else {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *recordingWriter) storedHeader() http.Header {
+	if w.header == nil {
+		return w.Header().Clone()
+	}
+	return w.header
+}
+
+func (w *recordingWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ravilushqa/boilerplate/internal/recovery"
+)
+
+// NewRecovery turns a panic in a handler into a 500 response carrying the
+// correlation ID under which the panic was logged and reported.
+func NewRecovery(h *recovery.Handler) mux.MiddlewareFunc {
+	type response struct {
+		Error         string `json:"error"`
+		CorrelationID string `json:"correlation_id"`
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				// ErrAbortHandler is the documented way to abort a response
+				if p == http.ErrAbortHandler { //nolint:errorlint
+					panic(p)
+				}
+				id := h.Handle(r.Context(), p, "http", map[string]string{
+					"method": r.Method,
+					"path":   r.URL.Path,
+				})
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("X-Correlation-Id", id)
+				w.WriteHeader(http.StatusInternalServerError)
+				_ = json.NewEncoder(w).Encode(response{Error: http.StatusText(http.StatusInternalServerError), CorrelationID: id})
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gorilla/mux"
+)
+
+// ValidationError is the body of requests rejected by NewValidation.
+type ValidationError struct {
+	Error      string   `json:"error"`
+	Violations []string `json:"violations"`
+}
+
+// NewValidation checks requests of the routes described in doc against it:
+// path and query parameters, headers and the body schema. Invalid requests
+// get 400 with the list of violations. Routes missing from doc are passed
+// through unchecked.
+//
+// With validateResponses responses are checked too, violations are logged
+// and the response is sent unchanged. It buffers response bodies and is meant
+// for development and staging, where a handler drifting from the document
+// should be noticed before clients do. Event streams and WebSocket
+// handshakes are not checked.
+//
+// It panics when doc cannot be routed, which is a programming error found by
+// the first test that builds the server.
+func NewValidation(l *slog.Logger, doc *openapi3.T, validateResponses bool) mux.MiddlewareFunc {
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		panic(fmt.Sprintf("validation: %v", err))
+	}
+	opts := &openapi3filter.Options{
+		MultiError:            true,
+		IncludeResponseStatus: true,
+		AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, params, err := router.FindRoute(r)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			in := &openapi3filter.RequestValidationInput{Request: r, PathParams: params, Route: route, Options: opts}
+			if err := openapi3filter.ValidateRequest(ctx, in); err != nil {
+				respondValidationError(w, violations(err))
+				return
+			}
+			if !validateResponses || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &recordingWriter{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			validateResponse(ctx, l, in, rec, route)
+		})
+	}
+}
+
+func validateResponse(ctx context.Context, l *slog.Logger, in *openapi3filter.RequestValidationInput, rec *recordingWriter, route *routers.Route) {
+	status := rec.status()
+	if rec.overflow || status == http.StatusNotModified || status >= http.StatusInternalServerError {
+		return
+	}
+	if mt, _, _ := mime.ParseMediaType(rec.storedHeader().Get("Content-Type")); mt == "text/event-stream" {
+		return
+	}
+	err := openapi3filter.ValidateResponse(ctx, &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: in,
+		Status:                 status,
+		Header:                 rec.storedHeader(),
+		Body:                   io.NopCloser(bytes.NewReader(rec.body.Bytes())),
+		Options:                in.Options,
+	})
+	if err != nil {
+		l.Warn("[HTTP] response does not match the OpenAPI document",
+			slog.String("method", in.Request.Method),
+			slog.String("path", route.Path),
+			slog.Int("status", status),
+			slog.Any("violations", violations(err)),
+		)
+	}
+}
+
+func respondValidationError(w http.ResponseWriter, v []string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(ValidationError{Error: "request validation failed", Violations: v})
+}
+
+// violations flattens the errors of the validation into one line each, e.g.
+// `query parameter "page_size": value must be an integer` or
+// `body /name: property "name" is missing`.
+func violations(err error) []string {
+	var out []string
+	var walk func(prefix string, err error)
+	walk = func(prefix string, err error) {
+		switch e := err.(type) {
+		case openapi3.MultiError:
+			for _, err := range e {
+				walk(prefix, err)
+			}
+		case *openapi3filter.RequestError:
+			switch {
+			case e.Parameter != nil:
+				prefix = fmt.Sprintf("%s parameter %q", e.Parameter.In, e.Parameter.Name)
+			case e.RequestBody != nil:
+				prefix = "body"
+			}
+			nested(&out, walk, prefix, e.Reason, e.Err)
+		case *openapi3filter.ResponseError:
+			nested(&out, walk, "response", e.Reason, e.Err)
+		case *openapi3.SchemaError:
+			if p := e.JSONPointer(); len(p) > 0 {
+				prefix += " /" + strings.Join(p, "/")
+			}
+			out = append(out, join(prefix, e.Reason))
+		default:
+			out = append(out, join(prefix, err.Error()))
+		}
+	}
+	walk("", err)
+	return out
+}
+
+// nested descends into the schema errors of a request or response error and
+// reports other causes with their reason.
+func nested(out *[]string, walk func(string, error), prefix, reason string, err error) {
+	switch err.(type) {
+	case openapi3.MultiError, *openapi3.SchemaError:
+		walk(prefix, err)
+		return
+	}
+	switch {
+	case err == nil:
+	case reason == "" || reason == err.Error():
+		reason = err.Error()
+	default:
+		reason += ": " + err.Error()
+	}
+	*out = append(*out, join(prefix, reason))
+}
+
+func join(prefix, msg string) string {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" {
+		return msg
+	}
+	return prefix + ": " + msg
+}
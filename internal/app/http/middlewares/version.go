@@ -0,0 +1,27 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ravilushqa/boilerplate/internal/apiversion"
+)
+
+// NewVersion marks the responses of the routes of version v with the
+// API-Version header, and the deprecation headers once v is deprecated, and
+// counts the requests v serves.
+func NewVersion(v apiversion.Version) mux.MiddlewareFunc {
+	deprecation := v.DeprecationHeaders()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set(apiversion.Header, v.Name)
+			for k, vals := range deprecation {
+				h[k] = vals
+			}
+			apiversion.Observe("http", v.Name, apiversion.Selection(r.Context()))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
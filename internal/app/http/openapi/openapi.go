@@ -0,0 +1,215 @@
+// Package openapi builds the OpenAPI 3 document of the HTTP API. Hand-written
+// mux routes are described with Add from their Go request and response
+// types, routes of gRPC methods exposed through the gateway with AddProto
+// from their google.api.http annotations.
+//
+// Struct fields are documented with tags next to the json tag:
+//
+//	Name string `json:"name" doc:"Who to greet" required:"true"`
+//
+// and query parameters with a query tag on the fields of Operation.Query.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3gen"
+)
+
+// Operation describes a hand-written route.
+type Operation struct {
+	ID          string
+	Summary     string
+	Description string
+	Tags        []string
+	// Query is a struct whose fields tagged with query are the query
+	// parameters.
+	Query any
+	// Request is the JSON request body, none when nil.
+	Request any
+	// Response is the JSON body of the successful response, none when nil.
+	Response any
+	// ContentType of the successful response when it is not JSON, e.g.
+	// "text/event-stream". Its body is described as a string, Response is
+	// ignored.
+	ContentType string
+	// Status of the successful response, 200 by default.
+	Status int
+	// Errors are the documented error statuses, answered with the error type
+	// of the Spec.
+	Errors []int
+}
+
+// Spec is an OpenAPI 3 document under construction.
+type Spec struct {
+	doc       *openapi3.T
+	errorType any
+}
+
+// New returns an empty document. Error responses are described by errorType.
+func New(title, version string, errorType any) *Spec {
+	return &Spec{
+		doc: &openapi3.T{
+			OpenAPI:    "3.0.3",
+			Info:       &openapi3.Info{Title: title, Version: version},
+			Paths:      openapi3.NewPaths(),
+			Components: &openapi3.Components{Schemas: openapi3.Schemas{}},
+		},
+		errorType: errorType,
+	}
+}
+
+// Document returns the document built so far.
+func (s *Spec) Document() *openapi3.T {
+	return s.doc
+}
+
+// Add describes the route of method and path. Path uses the mux template
+// syntax, variable patterns are dropped: "/items/{id:[0-9]+}" becomes
+// "/items/{id}". It panics on types that have no JSON schema, which is a
+// programming error found by the first test that builds the server.
+func (s *Spec) Add(method, path string, op Operation) {
+	path, params := muxPath(path)
+	o := &openapi3.Operation{
+		OperationID: op.ID,
+		Summary:     op.Summary,
+		Description: op.Description,
+		Tags:        op.Tags,
+		Responses:   openapi3.NewResponsesWithCapacity(0),
+	}
+	for _, p := range params {
+		o.AddParameter(openapi3.NewPathParameter(p).WithSchema(openapi3.NewStringSchema()))
+	}
+	if op.Query != nil {
+		for _, p := range queryParams(op.Query) {
+			o.AddParameter(p)
+		}
+	}
+	if op.Request != nil {
+		o.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().
+			WithRequired(true).
+			WithJSONSchemaRef(mustSchema(op.Request))}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := openapi3.NewResponse().WithDescription(http.StatusText(status))
+	switch {
+	case op.ContentType != "":
+		resp.Content = openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{op.ContentType})
+	case op.Response != nil:
+		resp.WithJSONSchemaRef(mustSchema(op.Response))
+	}
+	o.AddResponse(status, resp)
+	for _, code := range op.Errors {
+		s.addError(o, code)
+	}
+	s.doc.AddOperation(path, method, o)
+}
+
+func (s *Spec) addError(o *openapi3.Operation, code int) {
+	resp := openapi3.NewResponse().WithDescription(http.StatusText(code))
+	if s.errorType != nil {
+		resp.WithJSONSchemaRef(mustSchema(s.errorType))
+	}
+	o.AddResponse(code, resp)
+}
+
+// Routes returns the documented "METHOD /path" pairs, sorted.
+func (s *Spec) Routes() []string {
+	var routes []string
+	for path, item := range s.doc.Paths.Map() {
+		for method := range item.Operations() {
+			routes = append(routes, method+" "+path)
+		}
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// ServeHTTP serves the document as JSON.
+func (s *Spec) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.doc)
+}
+
+var muxVar = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// muxPath converts a mux path template and returns its variables.
+func muxPath(path string) (string, []string) {
+	var params []string
+	path = muxVar.ReplaceAllStringFunc(path, func(v string) string {
+		name := muxVar.FindStringSubmatch(v)[1]
+		params = append(params, name)
+		return "{" + name + "}"
+	})
+	return path, params
+}
+
+func queryParams(v any) []*openapi3.Parameter {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var params []*openapi3.Parameter
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := f.Tag.Lookup("query")
+		if !ok || name == "-" {
+			continue
+		}
+		ref, err := generator().GenerateSchemaRef(f.Type)
+		if err != nil {
+			panic(fmt.Sprintf("openapi: query parameter %s: %v", name, err))
+		}
+		p := openapi3.NewQueryParameter(name).WithSchema(ref.Value)
+		p.Description = f.Tag.Get("doc")
+		p.Required, _ = strconv.ParseBool(f.Tag.Get("required"))
+		params = append(params, p)
+	}
+	return params
+}
+
+func mustSchema(v any) *openapi3.SchemaRef {
+	ref, err := generator().NewSchemaRefForValue(v, nil)
+	if err != nil {
+		panic(fmt.Sprintf("openapi: schema of %T: %v", v, err))
+	}
+	return ref
+}
+
+func generator() *openapi3gen.Generator {
+	return openapi3gen.NewGenerator(openapi3gen.SchemaCustomizer(customize))
+}
+
+// customize applies the doc and required tags.
+func customize(_ string, t reflect.Type, tag reflect.StructTag, schema *openapi3.Schema) error {
+	if doc := tag.Get("doc"); doc != "" {
+		schema.Description = doc
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if ok, _ := strconv.ParseBool(f.Tag.Get("required")); !ok {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" {
+			name = f.Name
+		}
+		schema.Required = append(schema.Required, name)
+	}
+	return nil
+}
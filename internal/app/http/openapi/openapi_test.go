@@ -0,0 +1,136 @@
+package openapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestSpec_Add(t *testing.T) {
+	type query struct {
+		PageSize int    `query:"page_size" doc:"Max items returned"`
+		Filter   string `query:"filter" required:"true"`
+		Ignored  string
+	}
+	type item struct {
+		ID   string   `json:"id" required:"true"`
+		Tags []string `json:"tags,omitempty" doc:"Labels"`
+	}
+
+	s := New("test", "1.0.0", struct {
+		Error string `json:"error"`
+	}{})
+	s.Add(http.MethodGet, "/items/{id:[0-9]+}", Operation{ID: "getItem", Query: query{}, Response: item{}, Errors: []int{http.StatusNotFound}})
+	s.Add(http.MethodPut, "/items/{id}", Operation{ID: "putItem", Request: item{}, Status: http.StatusNoContent})
+	require.NoError(t, s.Document().Validate(context.Background()))
+	require.Equal(t, []string{"GET /items/{id}", "PUT /items/{id}"}, s.Routes())
+
+	get := s.Document().Paths.Find("/items/{id}").Get
+	require.Equal(t, "id", get.Parameters.GetByInAndName("path", "id").Name)
+	require.True(t, get.Parameters.GetByInAndName("query", "filter").Required)
+	require.Equal(t, "Max items returned", get.Parameters.GetByInAndName("query", "page_size").Description)
+	require.Nil(t, get.Parameters.GetByInAndName("query", "Ignored"))
+
+	schema := get.Responses.Status(http.StatusOK).Value.Content.Get("application/json").Schema.Value
+	require.Equal(t, []string{"id"}, schema.Required)
+	require.Equal(t, "Labels", schema.Properties["tags"].Value.Description)
+	require.NotNil(t, get.Responses.Status(http.StatusNotFound))
+
+	put := s.Document().Paths.Find("/items/{id}").Put
+	require.NotNil(t, put.Responses.Status(http.StatusNoContent))
+	require.True(t, put.RequestBody.Value.Required)
+}
+
+func TestSpec_AddProto(t *testing.T) {
+	rule := func(r *annotations.HttpRule) *descriptorpb.MethodOptions {
+		opts := &descriptorpb.MethodOptions{}
+		proto.SetExtension(opts, annotations.E_Http, r)
+		return opts
+	}
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{Name: proto.String(name), Number: proto.Int32(num), Type: typ.Enum(), Label: label.Enum()}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	const (
+		optional = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		repeated = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	)
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("test/items.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Item"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+				field("size_bytes", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, optional, ""),
+				field("children", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, repeated, ".test.Item"),
+			}},
+			{Name: proto.String("ListItemsRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("parent", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+				field("page_size", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, optional, ""),
+			}},
+			{Name: proto.String("ListItemsResponse"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("items", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, repeated, ".test.Item"),
+			}},
+			{Name: proto.String("CreateItemRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("parent", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+				field("item", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional, ".test.Item"),
+			}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Items"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{
+					Name: proto.String("ListItems"), InputType: proto.String(".test.ListItemsRequest"), OutputType: proto.String(".test.ListItemsResponse"),
+					Options: rule(&annotations.HttpRule{Pattern: &annotations.HttpRule_Get{Get: "/v1/{parent=shelves/*}/items"}}),
+				},
+				{
+					Name: proto.String("CreateItem"), InputType: proto.String(".test.CreateItemRequest"), OutputType: proto.String(".test.Item"),
+					Options: rule(&annotations.HttpRule{Pattern: &annotations.HttpRule_Post{Post: "/v1/{parent=shelves/*}/items"}, Body: "item"}),
+				},
+				{
+					Name: proto.String("ListShelfItems"), InputType: proto.String(".test.ListItemsRequest"), OutputType: proto.String(".test.ListItemsResponse"),
+					Options: rule(&annotations.HttpRule{Pattern: &annotations.HttpRule_Get{Get: "/v1/{parent=shelves}/items:all"}}),
+				},
+				{
+					Name: proto.String("Unmapped"), InputType: proto.String(".test.Item"), OutputType: proto.String(".test.Item"),
+				},
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+
+	s := New("test", "1.0.0", nil)
+	routes := s.AddProto(fd.Services().Get(0))
+	require.NoError(t, s.Document().Validate(context.Background()))
+	require.Equal(t, []string{"GET /v1/{parent}/items", "POST /v1/{parent}/items", "GET /v1/shelves/items:all"}, routes)
+	require.Equal(t, []string{"GET /v1/shelves/items:all", "GET /v1/{parent}/items", "POST /v1/{parent}/items"}, s.Routes())
+
+	list := s.Document().Paths.Find("/v1/{parent}/items").Get
+	require.NotNil(t, list.Parameters.GetByInAndName("path", "parent"))
+	require.NotNil(t, list.Parameters.GetByInAndName("query", "pageSize"))
+	require.Nil(t, list.Parameters.GetByInAndName("query", "parent"))
+
+	all := s.Document().Paths.Find("/v1/shelves/items:all").Get
+	require.Nil(t, all.Parameters.GetByInAndName("query", "parent"), "bound to a literal")
+	require.NotNil(t, all.Parameters.GetByInAndName("query", "pageSize"))
+
+	create := s.Document().Paths.Find("/v1/{parent}/items").Post
+	require.Equal(t, "#/components/schemas/test.Item", create.RequestBody.Value.Content.Get("application/json").Schema.Ref)
+
+	item := s.Document().Components.Schemas["test.Item"].Value
+	require.Equal(t, "int64", item.Properties["sizeBytes"].Value.Format)
+	require.Equal(t, "#/components/schemas/test.Item", item.Properties["children"].Value.Items.Ref)
+	require.Contains(t, s.Document().Components.Schemas, "google.rpc.Status")
+}
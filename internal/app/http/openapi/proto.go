@@ -0,0 +1,232 @@
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// AddProto describes the methods of sd annotated with google.api.http, the
+// routes the gateway serves for them. Messages become component schemas
+// named after their full proto name, in the protojson encoding the gateway
+// uses. It returns the routes, in the form of Routes.
+func (s *Spec) AddProto(sd protoreflect.ServiceDescriptor) []string {
+	var routes []string
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		rule, ok := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+		if !ok || rule == nil {
+			continue
+		}
+		routes = append(routes, s.addRule(md, rule))
+		for _, b := range rule.GetAdditionalBindings() {
+			routes = append(routes, s.addRule(md, b))
+		}
+	}
+	return slices.DeleteFunc(routes, func(r string) bool { return r == "" })
+}
+
+func (s *Spec) addRule(md protoreflect.MethodDescriptor, rule *annotations.HttpRule) string {
+	var method, tpl string
+	switch p := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		method, tpl = http.MethodGet, p.Get
+	case *annotations.HttpRule_Put:
+		method, tpl = http.MethodPut, p.Put
+	case *annotations.HttpRule_Post:
+		method, tpl = http.MethodPost, p.Post
+	case *annotations.HttpRule_Delete:
+		method, tpl = http.MethodDelete, p.Delete
+	case *annotations.HttpRule_Patch:
+		method, tpl = http.MethodPatch, p.Patch
+	case *annotations.HttpRule_Custom:
+		method, tpl = strings.ToUpper(p.Custom.GetKind()), p.Custom.GetPath()
+	default:
+		return ""
+	}
+
+	path, params, fixed := protoPath(tpl)
+	o := &openapi3.Operation{
+		OperationID: string(md.Parent().Name()) + "_" + string(md.Name()),
+		Tags:        []string{string(md.Parent().Name())},
+		Responses:   openapi3.NewResponsesWithCapacity(0),
+	}
+	bound := map[string]bool{}
+	for _, f := range fixed {
+		bound[f] = true
+	}
+	for _, p := range params {
+		bound[p] = true
+		o.AddParameter(openapi3.NewPathParameter(p).WithSchema(openapi3.NewStringSchema()))
+	}
+
+	in := md.Input()
+	switch body := rule.GetBody(); body {
+	case "":
+		// fields not bound to the path are query parameters
+		fields := in.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			if bound[string(fd.Name())] || fd.Message() != nil {
+				continue
+			}
+			o.AddParameter(openapi3.NewQueryParameter(fd.JSONName()).WithSchema(s.fieldSchema(fd).Value))
+		}
+	case "*":
+		o.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().
+			WithRequired(true).WithJSONSchemaRef(s.messageSchema(in))}
+	default:
+		if fd := in.Fields().ByName(protoreflect.Name(body)); fd != nil {
+			o.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().
+				WithRequired(true).WithJSONSchemaRef(s.fieldSchema(fd))}
+		}
+	}
+
+	out := s.messageSchema(md.Output())
+	if rb := rule.GetResponseBody(); rb != "" {
+		if fd := md.Output().Fields().ByName(protoreflect.Name(rb)); fd != nil {
+			out = s.fieldSchema(fd)
+		}
+	}
+	o.AddResponse(http.StatusOK, openapi3.NewResponse().WithDescription(http.StatusText(http.StatusOK)).WithJSONSchemaRef(out))
+	o.Responses.Set("default", &openapi3.ResponseRef{Value: openapi3.NewResponse().
+		WithDescription("Error").
+		WithJSONSchemaRef(s.messageSchema((&spb.Status{}).ProtoReflect().Descriptor()))})
+	s.doc.AddOperation(path, method, o)
+	return method + " " + path
+}
+
+var protoVar = regexp.MustCompile(`\{([^}=]+)(=[^}]*)?\}`)
+
+// protoPath converts a google.api.http path template, e.g.
+// "/v1/{name=operations/**}" to "/v1/{name}", and returns its variables.
+// A variable matching a literal, e.g. "/v1/{name=operations}", is not a
+// parameter: the literal replaces it and its field is returned in fixed.
+// The verb suffix ":cancel" is kept as part of the path.
+func protoPath(tpl string) (path string, params, fixed []string) {
+	path = protoVar.ReplaceAllStringFunc(tpl, func(v string) string {
+		m := protoVar.FindStringSubmatch(v)
+		if literal := strings.TrimPrefix(m[2], "="); literal != "" && !strings.Contains(literal, "*") {
+			fixed = append(fixed, m[1])
+			return literal
+		}
+		params = append(params, m[1])
+		return "{" + m[1] + "}"
+	})
+	return path, params, fixed
+}
+
+// messageSchema returns a reference to the component schema of md, adding it
+// on first use.
+func (s *Spec) messageSchema(md protoreflect.MessageDescriptor) *openapi3.SchemaRef {
+	if wk := wellKnown(md.FullName()); wk != nil {
+		return openapi3.NewSchemaRef("", wk)
+	}
+	name := string(md.FullName())
+	if c, ok := s.doc.Components.Schemas[name]; ok {
+		return openapi3.NewSchemaRef("#/components/schemas/"+name, c.Value)
+	}
+	schema := openapi3.NewObjectSchema()
+	// registered before the fields, so recursive messages end in a reference
+	s.doc.Components.Schemas[name] = openapi3.NewSchemaRef("", schema)
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		schema.WithPropertyRef(fd.JSONName(), s.fieldSchema(fd))
+	}
+	return openapi3.NewSchemaRef("#/components/schemas/"+name, schema)
+}
+
+func (s *Spec) fieldSchema(fd protoreflect.FieldDescriptor) *openapi3.SchemaRef {
+	switch {
+	case fd.IsMap():
+		schema := openapi3.NewObjectSchema()
+		schema.AdditionalProperties = openapi3.AdditionalProperties{Schema: s.singularSchema(fd.MapValue())}
+		return openapi3.NewSchemaRef("", schema)
+	case fd.IsList():
+		return openapi3.NewSchemaRef("", &openapi3.Schema{
+			Type:  &openapi3.Types{openapi3.TypeArray},
+			Items: s.singularSchema(fd),
+		})
+	}
+	return s.singularSchema(fd)
+}
+
+// singularSchema follows the protojson mapping: 64-bit integers are strings,
+// enums their value names and bytes base64.
+func (s *Spec) singularSchema(fd protoreflect.FieldDescriptor) *openapi3.SchemaRef {
+	var schema *openapi3.Schema
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		schema = openapi3.NewBoolSchema()
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		schema = openapi3.NewInt32Schema()
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		schema = openapi3.NewInt64Schema().WithMin(0)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		schema = openapi3.NewStringSchema().WithFormat("int64")
+	case protoreflect.FloatKind:
+		schema = openapi3.NewFloat64Schema().WithFormat("float")
+	case protoreflect.DoubleKind:
+		schema = openapi3.NewFloat64Schema().WithFormat("double")
+	case protoreflect.StringKind:
+		schema = openapi3.NewStringSchema()
+	case protoreflect.BytesKind:
+		schema = openapi3.NewBytesSchema()
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		names := make([]any, 0, values.Len())
+		for i := 0; i < values.Len(); i++ {
+			names = append(names, string(values.Get(i).Name()))
+		}
+		schema = openapi3.NewStringSchema().WithEnum(names...)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return s.messageSchema(fd.Message())
+	default:
+		schema = openapi3.NewSchema()
+	}
+	return openapi3.NewSchemaRef("", schema)
+}
+
+// wellKnown returns the schema of well-known types protojson encodes
+// specially, nil for other messages.
+func wellKnown(name protoreflect.FullName) *openapi3.Schema {
+	switch name {
+	case "google.protobuf.Timestamp":
+		return openapi3.NewDateTimeSchema()
+	case "google.protobuf.Duration":
+		return openapi3.NewStringSchema().WithPattern(`^-?[0-9]+(\.[0-9]+)?s$`)
+	case "google.protobuf.FieldMask":
+		return openapi3.NewStringSchema()
+	case "google.protobuf.Empty":
+		return openapi3.NewObjectSchema()
+	case "google.protobuf.Struct", "google.protobuf.Any":
+		return openapi3.NewObjectSchema().WithAnyAdditionalProperties()
+	case "google.protobuf.Value":
+		return openapi3.NewSchema()
+	case "google.protobuf.StringValue":
+		return openapi3.NewStringSchema()
+	case "google.protobuf.BoolValue":
+		return openapi3.NewBoolSchema()
+	case "google.protobuf.Int32Value":
+		return openapi3.NewInt32Schema()
+	case "google.protobuf.UInt32Value":
+		return openapi3.NewInt64Schema().WithMin(0)
+	case "google.protobuf.Int64Value", "google.protobuf.UInt64Value":
+		return openapi3.NewStringSchema().WithFormat("int64")
+	case "google.protobuf.FloatValue", "google.protobuf.DoubleValue":
+		return openapi3.NewFloat64Schema()
+	case "google.protobuf.BytesValue":
+		return openapi3.NewBytesSchema()
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package openapi
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed docs.html
+var docsHTML string
+
+var docsTemplate = template.Must(template.New("docs").Parse(docsHTML))
+
+// ui holds the Swagger UI assets, vendored from swagger-ui-dist by
+// make swagger-ui, and the script rendering the document.
+//
+//go:embed ui/*.js ui/*.css
+var ui embed.FS
+
+// UIHandler serves a Swagger UI page rendering the document at specURL. The
+// page loads its assets from assetsURL, ending with a slash, where
+// AssetsHandler is served.
+func UIHandler(specURL, assetsURL string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = docsTemplate.Execute(w, struct{ SpecURL, AssetsURL string }{specURL, assetsURL})
+	})
+}
+
+// AssetsHandler serves the assets of the Swagger UI page by file name. They
+// are embedded, so the page works offline and loads no third-party script.
+func AssetsHandler() http.Handler {
+	assets, err := fs.Sub(ui, "ui")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServerFS(assets)
+}
@@ -0,0 +1,7 @@
+// Renders the document whose URL is set on the #swagger-ui element. It is a
+// file rather than an inline script so that the page works under a
+// Content-Security-Policy without 'unsafe-inline'.
+window.onload = () => {
+  const dom = document.getElementById("swagger-ui");
+  window.ui = SwaggerUIBundle({url: dom.dataset.specUrl, domNode: dom});
+};
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/oasdiff/yaml"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ravilushqa/boilerplate/internal/operations"
+	"github.com/ravilushqa/boilerplate/internal/service"
+)
+
+var update = flag.Bool("update", false, "rewrite openapi.yaml from the routes")
+
+// specFile is the committed document, kept next to the other API
+// definitions for clients and reviewers.
+const specFile = "../../../openapi.yaml"
+
+func TestOpenAPI(t *testing.T) {
+	router := mux.NewRouter()
+	s := New(slog.Default(), router, "", service.NewGreeter(),
+		WithOperations(operations.NewManager(slog.Default(), operations.NewMemoryStore(time.Hour))))
+	doc := s.Spec().Document()
+	require.NoError(t, doc.Validate(context.Background()))
+
+	t.Run("routes match the document", func(t *testing.T) {
+		undocumented := map[string]bool{"GET /openapi.json": true, "GET /docs": true, "GET /docs/": true}
+		var routes []string
+		_ = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			if strings.HasPrefix(route.GetName(), gatewayRoute) {
+				return nil
+			}
+			tpl, err := route.GetPathTemplate()
+			require.NoError(t, err)
+			methods, err := route.GetMethods()
+			require.NoError(t, err, "route %s accepts any method", tpl)
+			for _, m := range methods {
+				if r := m + " " + tpl; m != http.MethodHead && !undocumented[r] {
+					routes = append(routes, r)
+				}
+			}
+			return nil
+		})
+		sort.Strings(routes)
+		documented := slices.DeleteFunc(s.Spec().Routes(), func(r string) bool { return s.gatewayRoutes[r] })
+		require.NotEmpty(t, s.gatewayRoutes)
+		require.Equal(t, documented, routes)
+	})
+
+	t.Run("committed document is up to date", func(t *testing.T) {
+		b, err := yaml.Marshal(doc)
+		require.NoError(t, err)
+		if *update {
+			require.NoError(t, os.WriteFile(specFile, b, 0o644))
+		}
+		committed, err := os.ReadFile(specFile)
+		require.NoError(t, err)
+		require.Equal(t, string(committed), string(b), "run go test ./internal/app/http -run TestOpenAPI -update")
+	})
+
+	t.Run("served", func(t *testing.T) {
+		for _, url := range []string{"/openapi.json", "/docs", "/docs/swagger-ui-bundle.js", "/docs/swagger-ui.css", "/docs/docs.js"} {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+			require.Equal(t, http.StatusOK, rec.Code, url)
+			require.NotContains(t, rec.Body.String(), "unpkg.com", url)
+		}
+	})
+}
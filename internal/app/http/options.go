@@ -0,0 +1,81 @@
+package http
+
+import (
+	"github.com/ravilushqa/boilerplate/internal/app/http/middlewares"
+	"github.com/ravilushqa/boilerplate/internal/app/http/stream"
+	"github.com/ravilushqa/boilerplate/internal/idempotency"
+	"github.com/ravilushqa/boilerplate/internal/operations"
+	"github.com/ravilushqa/boilerplate/internal/recovery"
+)
+
+// Option configures the HTTP server.
+type Option interface {
+	apply(*Server)
+}
+
+type optionFunc func(*Server)
+
+func (o optionFunc) apply(s *Server) {
+	o(s)
+}
+
+// WithRecovery sets the handler of panics recovered in HTTP handlers.
+// By default panics are only logged.
+func WithRecovery(h *recovery.Handler) Option {
+	return optionFunc(func(s *Server) {
+		s.recovery = h
+	})
+}
+
+// WithIdempotency replays stored responses of POST and PATCH requests sent
+// again with the same Idempotency-Key header. Without it the header is
+// ignored.
+func WithIdempotency(store idempotency.Store) Option {
+	return optionFunc(func(s *Server) {
+		s.idempotency = store
+	})
+}
+
+// WithResponseCache keeps responses of GET routes with a cache TTL in rc.
+// Without it ETags and conditional requests still work, but responses are
+// computed on every request.
+func WithResponseCache(rc *middlewares.ResponseCache) Option {
+	return optionFunc(func(s *Server) {
+		s.cache = rc
+	})
+}
+
+// WithCompression compresses responses in the encoding the client accepts.
+// Responses are not compressed by default.
+func WithCompression(cfg middlewares.CompressionConfig) Option {
+	return optionFunc(func(s *Server) {
+		s.compression = &cfg
+	})
+}
+
+// WithValidation rejects requests that do not match the OpenAPI document of
+// the routes with 400. With validateResponses responses not matching it are
+// logged, which is meant for non-production environments.
+func WithValidation(validateResponses bool) Option {
+	return optionFunc(func(s *Server) {
+		s.validation = &validateResponses
+	})
+}
+
+// WithStreams configures the event streams and WebSockets of the server. By
+// default their number is unlimited.
+func WithStreams(cfg stream.Config) Option {
+	return optionFunc(func(s *Server) {
+		s.streamCfg = cfg
+	})
+}
+
+// WithOperations serves the google.longrunning.Operations service under
+// /v1/operations through the gateway, so HTTP clients can follow the
+// operations started by other routes and RPCs. Handlers start operations
+// with its Start.
+func WithOperations(m *operations.Manager) Option {
+	return optionFunc(func(s *Server) {
+		s.operations = m
+	})
+}
@@ -1,8 +1,131 @@
 package http
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"github.com/gorilla/mux"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	longrunninggw "github.com/ravilushqa/boilerplate/api/google/longrunning"
+
+	"github.com/ravilushqa/boilerplate/internal/apiversion"
+	"github.com/ravilushqa/boilerplate/internal/app/http/middlewares"
+	"github.com/ravilushqa/boilerplate/internal/app/http/openapi"
+)
+
+// defaultVersion serves requests to unversioned paths without an API-Version
+// header. It stays the oldest supported version, so clients that never
+// chose one are not broken by a new version.
+const defaultVersion = "v1"
 
 func (s *Server) routes() {
-	s.router.HandleFunc("/", s.handleRoot())
-	s.router.HandleFunc("/greet", s.handleGreet()).Methods(http.MethodPost)
+	v1 := s.version(apiversion.Version{Name: "v1"})
+	v1.handle(http.MethodGet, "/", openapi.Operation{
+		ID:       "root",
+		Summary:  "Describe the serving instance",
+		Response: rootResponse{},
+	}, s.cached(middlewares.CachePolicy{
+		Route:        "root",
+		CacheControl: "public, max-age=5",
+		TTL:          5 * time.Second,
+	}, s.handleRoot()))
+	v1.handle(http.MethodPost, "/greet", openapi.Operation{
+		ID:       "greet",
+		Summary:  "Greet someone",
+		Request:  greetRequest{},
+		Response: greetResponse{},
+		Errors:   []int{http.StatusBadRequest},
+	}, s.handleGreet())
+	v1.handle(http.MethodGet, "/greetings", openapi.Operation{
+		ID:       "listGreetings",
+		Summary:  "List recent greetings",
+		Query:    listGreetingsQuery{},
+		Response: listGreetingsResponse{},
+		Errors:   []int{http.StatusBadRequest},
+	}, s.handleListGreetings())
+	v1.handle(http.MethodGet, "/greet/stream", openapi.Operation{
+		ID:          "greetStream",
+		Summary:     "Stream greetings",
+		Description: "Server-sent events named greeting, one every interval_ms, whose data is the greeting as JSON.",
+		Query:       greetStreamQuery{},
+		ContentType: "text/event-stream",
+		Errors:      []int{http.StatusBadRequest, http.StatusServiceUnavailable},
+	}, s.handleGreetStream())
+	v1.handle(http.MethodGet, "/greet/ws", openapi.Operation{
+		ID:          "greetSocket",
+		Summary:     "Greet over a WebSocket",
+		Description: "Every greet request message sent on the WebSocket is answered with a greeting message.",
+		Status:      http.StatusSwitchingProtocols,
+		Errors:      []int{http.StatusServiceUnavailable},
+	}, s.handleGreetSocket())
+
+	if s.operations != nil {
+		if err := longrunninggw.RegisterOperationsHandlerServer(context.Background(), s.gateway, s.operations); err != nil {
+			panic(err)
+		}
+		if err := s.gateway.HandlePath(http.MethodGet, "/v1/operations", s.handleListOperations()); err != nil {
+			panic(err)
+		}
+		v1.proto(longrunningpb.File_google_longrunning_operations_proto.Services().ByName("Operations"), "/operations")
+	}
+
+	// documentation routes are not part of the document
+	s.router.Handle("/openapi.json", s.spec).Methods(http.MethodGet)
+	s.router.Handle("/docs", openapi.UIHandler("/openapi.json", "/docs/")).Methods(http.MethodGet)
+	s.router.PathPrefix("/docs/").Handler(http.StripPrefix("/docs/", openapi.AssetsHandler())).Methods(http.MethodGet)
+}
+
+// apiVersion registers the routes of one API version under its path prefix.
+// They are registered on the server's router rather than a subrouter: mux
+// answers 404 instead of 405 in a subrouter when a later route shares the
+// path prefix.
+type apiVersion struct {
+	s          *Server
+	name       string
+	middleware mux.MiddlewareFunc
+}
+
+// version adds v to the served versions. Deprecating a version is setting
+// its Deprecated, and later Sunset, time here.
+func (s *Server) version(v apiversion.Version) *apiVersion {
+	s.versions[v.Name] = v
+	return &apiVersion{s: s, name: v.Name, middleware: middlewares.NewVersion(v)}
+}
+
+// handle registers h for method and path of the version and describes the
+// route in the OpenAPI document. GET routes answer HEAD too, except streams.
+// Routes registered on the router directly are reported by the drift test.
+func (v *apiVersion) handle(method, path string, op openapi.Operation, h http.Handler) {
+	path = "/" + v.name + path
+	v.s.spec.Add(method, path, op)
+	methods := []string{method}
+	streaming := op.ContentType != "" || op.Status == http.StatusSwitchingProtocols
+	if method == http.MethodGet && !streaming {
+		methods = append(methods, http.MethodHead)
+	}
+	v.s.router.Handle(path, v.middleware(h)).Methods(methods...)
+}
+
+// gatewayRoute names the router routes served by the gateway.
+const gatewayRoute = "gateway"
+
+// proto serves the methods of sd annotated with google.api.http through the
+// gateway and describes them in the OpenAPI document. Their paths come from
+// the annotations, which start with the version; path is the prefix they
+// share after it. The document and the gateway are built from the same
+// descriptors, so the drift test skips these routes.
+func (v *apiVersion) proto(sd protoreflect.ServiceDescriptor, path string) {
+	for _, r := range v.s.spec.AddProto(sd) {
+		v.s.gatewayRoutes[r] = true
+	}
+	v.s.router.PathPrefix("/" + v.name + path).Handler(v.middleware(v.s.gateway)).Name(gatewayRoute + " " + path)
+}
+
+// cached serves GET requests of h with ETags and conditional requests, and
+// from the response cache when the server has one.
+func (s *Server) cached(policy middlewares.CachePolicy, h http.Handler) http.Handler {
+	return middlewares.NewCaching(policy, s.cache)(h)
 }
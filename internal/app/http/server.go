@@ -4,72 +4,177 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
 	"github.com/gorilla/mux"
+	gwruntime "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
 
+	"github.com/ravilushqa/boilerplate/internal/apiversion"
 	"github.com/ravilushqa/boilerplate/internal/app/http/middlewares"
+	"github.com/ravilushqa/boilerplate/internal/app/http/openapi"
+	"github.com/ravilushqa/boilerplate/internal/app/http/stream"
+	"github.com/ravilushqa/boilerplate/internal/idempotency"
+	"github.com/ravilushqa/boilerplate/internal/listing"
+	"github.com/ravilushqa/boilerplate/internal/operations"
+	"github.com/ravilushqa/boilerplate/internal/recovery"
+	"github.com/ravilushqa/boilerplate/internal/service"
 )
 
-var errNameRequired = errors.New("name is required")
-
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error string `json:"error" required:"true"`
 }
 
 type Server struct {
-	l      *slog.Logger
-	router *mux.Router
-	srv    *http.Server
+	l           *slog.Logger
+	router      *mux.Router
+	srv         *http.Server
+	greeter     service.Greeter
+	recovery    *recovery.Handler
+	idempotency idempotency.Store
+	cache       *middlewares.ResponseCache
+	compression *middlewares.CompressionConfig
+	spec        *openapi.Spec
+	versions    map[string]apiversion.Version
+	streamCfg   stream.Config
+	streams     *stream.Streams
+	operations  *operations.Manager
+	// gateway serves the gRPC services mapped to HTTP with google.api.http,
+	// gatewayRoutes are their documented routes
+	gateway       *gwruntime.ServeMux
+	gatewayRoutes map[string]bool
+	// validation is set when requests are validated, true when responses are
+	// validated too
+	validation *bool
 }
 
-func New(l *slog.Logger, router *mux.Router, addr string) *Server {
-	s := &Server{l: l, router: router}
+func New(l *slog.Logger, router *mux.Router, addr string, greeter service.Greeter, opts ...Option) *Server {
+	s := &Server{l: l, router: router, greeter: greeter, spec: openapi.New("Boilerplate API", "1.0.0", ErrorResponse{})}
+	s.versions = map[string]apiversion.Version{}
+	s.gatewayRoutes = map[string]bool{}
+	for _, opt := range opts {
+		opt.apply(s)
+	}
+	if s.recovery == nil {
+		s.recovery = recovery.New(l, nil)
+	}
+	s.streams = stream.New(l, s.streamCfg)
+	// unset fields are left out like in the JSON of the other routes
+	s.gateway = gwruntime.NewServeMux(gwruntime.WithMarshalerOption(gwruntime.MIMEWildcard, &gwruntime.HTTPBodyMarshaler{
+		Marshaler: &gwruntime.JSONPb{UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true}},
+	}))
 	s.routes()
-	s.router.Use(middlewares.NewLogging(l))
+	s.router.Use(middlewares.NewLogging(l), middlewares.NewRecovery(s.recovery))
+	if s.compression != nil {
+		s.router.Use(middlewares.NewCompression(*s.compression))
+	}
+	if s.idempotency != nil {
+		s.router.Use(middlewares.NewIdempotency(l, s.idempotency))
+	}
+	if s.validation != nil {
+		s.router.Use(middlewares.NewValidation(l, s.spec.Document(), *s.validation))
+	}
 	s.srv = &http.Server{
 		Addr:         addr,
 		Handler:      s,
 		WriteTimeout: 15 * time.Second,
 		ReadTimeout:  15 * time.Second,
 	}
+	// Shutdown does not end streams, they are asked to close
+	s.srv.RegisterOnShutdown(s.streams.Shutdown)
 	return s
 }
 
 func (s *Server) Run(ctx context.Context) error {
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		<-ctx.Done()
 		s.l.Info("[HTTP] server stopping", slog.String("addr", s.srv.Addr))
-		err := s.srv.Shutdown(ctx)
+		// drain in-flight requests, the caller bounds how long it waits for Run
+		err := s.srv.Shutdown(context.WithoutCancel(ctx))
 		if err != nil {
-			s.l.Error("[HTTP] server shutdown error", err)
+			s.l.Error("[HTTP] server shutdown error", slog.Any("error", err))
 		}
+		// hijacked WebSocket connections are not tracked by Shutdown
+		s.streams.Wait()
 	}()
 	s.l.Info("[HTTP] server listening", slog.String("addr", s.srv.Addr))
 	if err := s.srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
+	<-stopped
 	return nil
 }
 
+// Spec returns the OpenAPI document of the routes.
+func (s *Server) Spec() *openapi.Spec {
+	return s.spec
+}
+
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, err := s.selectVersion(w, r)
+	if err != nil {
+		s.respond(w, r, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
 	s.router.ServeHTTP(w, r)
 }
 
-func (s *Server) handleRoot() http.HandlerFunc {
-	type response struct {
-		Message  string `json:"message"`
-		Hostname string `json:"hostname"`
-		MaxProcs int    `json:"max_procs"`
-		NumCPU   int    `json:"num_cpu"`
+// selectVersion routes a request to an unversioned path, e.g. /greet, to the
+// version in its API-Version header, or to defaultVersion, by prefixing the
+// path. Requests matching no versioned route are left as they are.
+func (s *Server) selectVersion(w http.ResponseWriter, r *http.Request) (*http.Request, error) {
+	first, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if _, ok := s.versions[first]; ok {
+		return r, nil
+	}
+	name, selection := r.Header.Get(apiversion.Header), apiversion.SelectedByHeader
+	if name == "" {
+		name, selection = defaultVersion, apiversion.SelectedByDefault
 	}
+	if _, ok := s.versions[name]; !ok {
+		return r, fmt.Errorf("unsupported API version %q", name)
+	}
+
+	versioned := r.WithContext(apiversion.WithSelection(r.Context(), selection))
+	u := *r.URL
+	u.Path = "/" + name + u.Path
+	if u.RawPath != "" {
+		u.RawPath = "/" + name + u.RawPath
+	}
+	versioned.URL = &u
+	var match mux.RouteMatch
+	if !s.router.Match(versioned, &match) && !errors.Is(match.MatchErr, mux.ErrMethodMismatch) {
+		return r, nil
+	}
+	// the response depends on the header, caches must key on it
+	w.Header().Add("Vary", apiversion.Header)
+	return versioned, nil
+}
+
+type rootResponse struct {
+	Message  string `json:"message"`
+	Hostname string `json:"hostname" doc:"Host name of the serving instance"`
+	MaxProcs int    `json:"max_procs" doc:"GOMAXPROCS of the serving instance"`
+	NumCPU   int    `json:"num_cpu" doc:"Number of CPUs visible to the serving instance"`
+}
+
+func (s *Server) handleRoot() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		hostname, _ := os.Hostname()
-		s.respond(w, r, http.StatusOK, response{
+		s.respond(w, r, http.StatusOK, rootResponse{
 			Message:  "Hello World",
 			Hostname: hostname,
 			MaxProcs: runtime.GOMAXPROCS(0),
@@ -78,39 +183,223 @@ func (s *Server) handleRoot() http.HandlerFunc {
 	}
 }
 
+type greetRequest struct {
+	Name string `json:"name" doc:"Who to greet" required:"true"`
+}
+
+type greetResponse struct {
+	Greeting string `json:"greeting"`
+}
+
 func (s *Server) handleGreet() http.HandlerFunc {
-	type request struct {
-		Name string
-	}
-	type response struct {
-		Greeting string `json:"greeting"`
-	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		var req request
+		var req greetRequest
 		if err := s.decode(w, r, &req); err != nil {
-			s.l.Error("failed to decode request", err)
+			s.l.Error("failed to decode request", slog.Any("error", err))
 			s.respond(w, r, http.StatusBadRequest, nil)
 			return
 		}
 
-		if req.Name == "" {
-			s.respond(w, r, http.StatusBadRequest, ErrorResponse{Error: errNameRequired.Error()})
+		greeting, err := s.greeter.Greet(r.Context(), req.Name)
+		if err != nil {
+			s.respondError(w, r, err)
 			return
 		}
 
-		s.respond(w, r, http.StatusOK, response{Greeting: "Hello " + req.Name})
+		s.respond(w, r, http.StatusOK, greetResponse{Greeting: greeting})
 	}
 }
 
+type greetStreamQuery struct {
+	Name       string `query:"name" doc:"Who to greet" required:"true"`
+	Count      int    `query:"count" doc:"Number of greetings, 1 to 100" required:"true"`
+	IntervalMs int    `query:"interval_ms" doc:"Delay between greetings in milliseconds, at most 10000"`
+}
+
+const (
+	maxStreamCount    = 100
+	maxStreamInterval = 10 * time.Second
+)
+
+// handleGreetStream sends count greetings as server-sent events, the HTTP
+// counterpart of the GreetStream RPC.
+func (s *Server) handleGreetStream() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		count, err := strconv.Atoi(q.Get("count"))
+		if err != nil || count < 1 || count > maxStreamCount {
+			s.respond(w, r, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("count must be between 1 and %d", maxStreamCount)})
+			return
+		}
+		var interval time.Duration
+		if v := q.Get("interval_ms"); v != "" {
+			ms, err := strconv.Atoi(v)
+			interval = time.Duration(ms) * time.Millisecond
+			if err != nil || ms < 0 || interval > maxStreamInterval {
+				s.respond(w, r, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("interval must be at most %s", maxStreamInterval)})
+				return
+			}
+		}
+		greeting, err := s.greeter.Greet(r.Context(), q.Get("name"))
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		s.streams.SSE(func(ctx context.Context, _ *http.Request, events *stream.Events) error {
+			t := time.NewTimer(0)
+			defer t.Stop()
+			for i := 1; i <= count; i++ {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-t.C:
+				}
+				err := events.Send(stream.Event{ID: strconv.Itoa(i), Name: "greeting", Data: greetResponse{Greeting: greeting}})
+				if err != nil {
+					return err
+				}
+				t.Reset(interval)
+			}
+			return nil
+		}).ServeHTTP(w, r)
+	}
+}
+
+type listGreetingsQuery struct {
+	PageSize  int    `query:"page_size" doc:"Maximum number of greetings to return, 50 when 0, at most 1000"`
+	PageToken string `query:"page_token" doc:"next_page_token of the previous page, with the same filter and order_by"`
+	Filter    string `query:"filter" doc:"AIP-160 filter on name, message and create_time, e.g. name = \"World\""`
+	OrderBy   string `query:"order_by" doc:"Comma separated fields, each optionally followed by desc, create_time desc by default"`
+}
+
+type greeting struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Message    string    `json:"message"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+type listGreetingsResponse struct {
+	Greetings     []greeting `json:"greetings"`
+	NextPageToken string     `json:"next_page_token,omitempty" doc:"Token of the next page, absent on the last page"`
+}
+
+// handleListGreetings lists the recent greetings, the HTTP counterpart of
+// the ListGreetings RPC.
+func (s *Server) handleListGreetings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := listing.FromQuery(r.URL.Query())
+		if err != nil {
+			s.respond(w, r, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		greetings, next, err := s.greeter.ListGreetings(r.Context(), req)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		resp := listGreetingsResponse{Greetings: make([]greeting, len(greetings)), NextPageToken: next}
+		for i, g := range greetings {
+			resp.Greetings[i] = greeting{ID: g.ID, Name: g.Name, Message: g.Message, CreateTime: g.CreateTime}
+		}
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+// handleListOperations serves ListOperations through the gateway. The
+// generated mapping of GetOperation, /v1/{name=operations/**}, also matches
+// /v1/operations and the gateway tries it first.
+func (s *Server) handleListOperations() gwruntime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		_, marshaler := gwruntime.MarshalerForRequest(s.gateway, r)
+		req := &longrunningpb.ListOperationsRequest{}
+		err := gwruntime.PopulateQueryParameters(req, r.URL.Query(), utilities.NewDoubleArray(nil))
+		if err != nil {
+			gwruntime.HTTPError(r.Context(), s.gateway, marshaler, w, r, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+		req.Name = "operations"
+		resp, err := s.operations.ListOperations(r.Context(), req)
+		if err != nil {
+			gwruntime.HTTPError(r.Context(), s.gateway, marshaler, w, r, err)
+			return
+		}
+		gwruntime.ForwardResponseMessage(r.Context(), s.gateway, marshaler, w, r, resp)
+	}
+}
+
+// handleGreetSocket replies with a greeting to every greetRequest message,
+// the HTTP counterpart of the Chat RPC. Invalid messages and service errors
+// are answered with an ErrorResponse message and the connection stays open.
+func (s *Server) handleGreetSocket() http.Handler {
+	return s.streams.WebSocket(func(ctx context.Context, r *http.Request, conn *stream.Conn) error {
+		for {
+			var req greetRequest
+			err := conn.ReadJSON(&req)
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			switch {
+			case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+				err = conn.WriteJSON(ErrorResponse{Error: "invalid message"})
+			case err != nil:
+				return err
+			default:
+				var greeting string
+				if greeting, err = s.greeter.Greet(ctx, req.Name); err != nil {
+					_, resp := s.toError(r, err)
+					err = conn.WriteJSON(resp)
+				} else {
+					err = conn.WriteJSON(greetResponse{Greeting: greeting})
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+}
+
 func (s *Server) respond(w http.ResponseWriter, _ *http.Request, status int, data interface{}) {
+	if data != nil {
+		w.Header().Set("Content-Type", "application/json")
+	}
 	w.WriteHeader(status)
 	if data != nil {
 		if err := json.NewEncoder(w).Encode(data); err != nil {
-			s.l.Error("failed to encode response", err)
+			s.l.Error("failed to encode response", slog.Any("error", err))
 		}
 	}
 }
 
+// respondError maps a service error to a status code.
+func (s *Server) respondError(w http.ResponseWriter, r *http.Request, err error) {
+	status, resp := s.toError(r, err)
+	s.respond(w, r, status, resp)
+}
+
+// toError maps a service error to a status code and response. Messages of
+// internal errors are logged and not exposed to the client.
+func (s *Server) toError(r *http.Request, err error) (int, ErrorResponse) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrInvalidArgument):
+		status = http.StatusBadRequest
+	case errors.Is(err, service.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrConflict):
+		status = http.StatusConflict
+	}
+
+	msg := err.Error()
+	if status == http.StatusInternalServerError {
+		s.l.Error("request failed", slog.String("path", r.URL.Path), slog.Any("error", err))
+		msg = http.StatusText(status)
+	}
+	return status, ErrorResponse{Error: msg}
+}
+
 func (s *Server) decode(_ http.ResponseWriter, r *http.Request, v interface{}) error {
 	return json.NewDecoder(r.Body).Decode(v)
 }
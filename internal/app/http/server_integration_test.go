@@ -12,6 +12,8 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/require"
+
+	"github.com/ravilushqa/boilerplate/internal/service"
 )
 
 const (
@@ -20,7 +22,7 @@ const (
 )
 
 func Test_server_integration(t *testing.T) {
-	s := New(slog.Default(), mux.NewRouter(), srvAddr)
+	s := New(slog.Default(), mux.NewRouter(), srvAddr, service.NewGreeter())
 	ctx, cancel := context.WithCancel(context.Background())
 
 	wg := &sync.WaitGroup{}
@@ -2,16 +2,35 @@ package http
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
 	tests "github.com/gophermodz/http/httptest"
 	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/ravilushqa/boilerplate/internal/apiversion"
+	"github.com/ravilushqa/boilerplate/internal/app/http/middlewares"
+	"github.com/ravilushqa/boilerplate/internal/app/http/openapi"
+	"github.com/ravilushqa/boilerplate/internal/idempotency"
+	"github.com/ravilushqa/boilerplate/internal/operations"
+	"github.com/ravilushqa/boilerplate/internal/service"
 )
 
 func Test_server(t *testing.T) {
-	h := New(slog.Default(), mux.NewRouter(), "")
+	h := New(slog.Default(), mux.NewRouter(), "", service.NewGreeter())
 	t.Run("greet", func(t *testing.T) {
 		scenarios := []tests.APIScenario{
 			{
@@ -54,4 +73,326 @@ func Test_server(t *testing.T) {
 			scenario.Test(t)
 		}
 	})
+
+	t.Run("list greetings", func(t *testing.T) {
+		scenarios := []tests.APIScenario{
+			{
+				Name:            "success",
+				Method:          http.MethodGet,
+				URL:             "/greetings?filter=" + url.QueryEscape(`name = "World"`) + "&page_size=1",
+				ExpectedStatus:  http.StatusOK,
+				ExpectedContent: []string{`"name":"World","message":"Hello World"`},
+				Handler:         h,
+			},
+			{
+				Name:            "invalid page_size",
+				Method:          http.MethodGet,
+				URL:             "/greetings?page_size=ten",
+				ExpectedStatus:  http.StatusBadRequest,
+				ExpectedContent: []string{`{"error":"page_size must be an integer"}`},
+				Handler:         h,
+			},
+			{
+				Name:            "invalid order_by",
+				Method:          http.MethodGet,
+				URL:             "/greetings?order_by=age",
+				ExpectedStatus:  http.StatusBadRequest,
+				ExpectedContent: []string{`{"error":"invalid order_by: unknown field \"age\""}`},
+				Handler:         h,
+			},
+		}
+		for _, scenario := range scenarios {
+			scenario.Test(t)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		r := mux.NewRouter()
+		New(slog.Default(), r, "", service.NewGreeter())
+		r.HandleFunc("/panic", func(http.ResponseWriter, *http.Request) { panic("boom") })
+
+		scenario := tests.APIScenario{
+			Name:            "recovered",
+			Method:          http.MethodGet,
+			URL:             "/panic",
+			ExpectedStatus:  http.StatusInternalServerError,
+			ExpectedContent: []string{`"error":"Internal Server Error"`, `"correlation_id":"`},
+			Handler:         r,
+		}
+		scenario.Test(t)
+	})
+
+	t.Run("idempotency", func(t *testing.T) {
+		g := &countingGreeter{Greeter: service.NewGreeter()}
+		h := New(slog.Default(), mux.NewRouter(), "", g, WithIdempotency(idempotency.NewMemoryStore(time.Minute)))
+		greet := func(key, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/greet", strings.NewReader(body))
+			req.Header.Set("Idempotency-Key", key)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			return rec
+		}
+
+		first := greet("k1", `{"name":"World"}`)
+		require.Equal(t, http.StatusOK, first.Code)
+		second := greet("k1", `{"name":"World"}`)
+		require.Equal(t, http.StatusOK, second.Code)
+		require.Equal(t, first.Body.String(), second.Body.String())
+		require.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+		require.Equal(t, 1, g.calls)
+
+		require.Equal(t, http.StatusUnprocessableEntity, greet("k1", `{"name":"Gopher"}`).Code)
+		require.Equal(t, http.StatusOK, greet("k2", `{"name":"Gopher"}`).Code)
+		require.Equal(t, 2, g.calls)
+
+		tooLarge := greet("k3", `{"name":"`+strings.Repeat("a", 2<<20)+`"}`)
+		require.Equal(t, http.StatusRequestEntityTooLarge, tooLarge.Code)
+		require.JSONEq(t, `{"error":"request body is too large"}`, tooLarge.Body.String())
+		require.Equal(t, 2, g.calls)
+	})
+
+	t.Run("caching", func(t *testing.T) {
+		h := New(slog.Default(), mux.NewRouter(), "", service.NewGreeter(), WithResponseCache(middlewares.NewResponseCache(10)))
+		get := func(header, value string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if header != "" {
+				req.Header.Set(header, value)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			return rec
+		}
+
+		first := get("", "")
+		require.Equal(t, http.StatusOK, first.Code)
+		etag := first.Header().Get("ETag")
+		require.NotEmpty(t, etag)
+		require.Equal(t, "public, max-age=5", first.Header().Get("Cache-Control"))
+
+		rec := get("If-None-Match", `"other", `+etag)
+		require.Equal(t, http.StatusNotModified, rec.Code)
+		require.Empty(t, rec.Body.String())
+		require.Equal(t, etag, rec.Header().Get("ETag"))
+
+		require.Equal(t, http.StatusOK, get("If-None-Match", `"other"`).Code)
+		require.Equal(t, http.StatusNotModified, get("If-Modified-Since", first.Header().Get("Last-Modified")).Code)
+		require.Equal(t, http.StatusOK, get("If-Modified-Since", "Mon, 02 Jan 2006 15:04:05 GMT").Code)
+	})
+
+	t.Run("compression", func(t *testing.T) {
+		r := mux.NewRouter()
+		New(slog.Default(), r, "", service.NewGreeter(), WithCompression(middlewares.CompressionConfig{MinSize: 100}))
+		r.HandleFunc("/big", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`"` + strings.Repeat("a", 1000) + `"`))
+		})
+		r.HandleFunc("/png", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(bytes.Repeat([]byte{0}, 1000))
+		})
+		get := func(url, accept string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, url, nil)
+			req.Header.Set("Accept-Encoding", accept)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			return rec
+		}
+
+		rec := get("/big", "gzip, br;q=0.5")
+		require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		zr, err := gzip.NewReader(rec.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		require.Len(t, body, 1002)
+
+		require.Equal(t, "zstd", get("/big", "gzip, zstd, br").Header().Get("Content-Encoding"))
+		require.Equal(t, "br", get("/big", "gzip;q=0.1, br").Header().Get("Content-Encoding"))
+		require.Empty(t, get("/big", "identity").Header().Get("Content-Encoding"))
+		require.Empty(t, get("/png", "gzip").Header().Get("Content-Encoding"), "not compressible")
+		rec = get("/v1/", "gzip")
+		require.Empty(t, rec.Header().Get("Content-Encoding"), "below min size")
+		require.Contains(t, rec.Body.String(), "Hello World")
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		logs := &bytes.Buffer{}
+		l := slog.New(slog.NewTextHandler(logs, nil))
+		g := &conflictingGreeter{Greeter: service.NewGreeter()}
+		h := New(l, mux.NewRouter(), "", g, WithValidation(true))
+		post := func(body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/greet", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			return rec
+		}
+
+		rec := post(`{"name":"World"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NotContains(t, logs.String(), "level=WARN")
+
+		rec = post(`{}`)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		require.JSONEq(t, `{"error":"request validation failed","violations":["body /name: property \"name\" is missing"]}`, rec.Body.String())
+		rec = post(`{"name":1}`)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		require.Contains(t, rec.Body.String(), `body /name: value must be a string`)
+
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NotContains(t, logs.String(), "level=WARN")
+
+		// 409 is not documented for /greet
+		g.conflict = true
+		require.Equal(t, http.StatusConflict, post(`{"name":"World"}`).Code)
+		require.Contains(t, logs.String(), "response does not match the OpenAPI document")
+	})
+}
+
+func Test_server_versioning(t *testing.T) {
+	s := New(slog.Default(), mux.NewRouter(), "", service.NewGreeter())
+	v0 := s.version(apiversion.Version{
+		Name:       "v0",
+		Deprecated: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+		Sunset:     time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC),
+	})
+	v0.handle(http.MethodGet, "/old", openapi.Operation{ID: "old"}, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	serve := func(method, url, version string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(`{"name":"World"}`))
+		if version != "" {
+			req.Header.Set(apiversion.Header, version)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/v1/greet", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "v1", rec.Header().Get(apiversion.Header))
+	require.Empty(t, rec.Header().Get("Vary"))
+	require.Empty(t, rec.Header().Get("Deprecation"))
+
+	rec = serve(http.MethodPost, "/greet", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "v1", rec.Header().Get(apiversion.Header), "default version")
+	require.Equal(t, apiversion.Header, rec.Header().Get("Vary"))
+
+	rec = serve(http.MethodGet, "/old", "v0")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "v0", rec.Header().Get(apiversion.Header))
+	require.Equal(t, "@1792368000", rec.Header().Get("Deprecation"))
+	require.Equal(t, "Thu, 01 Apr 2027 00:00:00 GMT", rec.Header().Get("Sunset"))
+
+	require.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/old", "").Code, "not in the default version")
+	require.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/greet", "v0").Code)
+	require.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodGet, "/greet", "").Code)
+	rec = serve(http.MethodPost, "/greet", "v9")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), `unsupported API version \"v9\"`)
+	require.Equal(t, http.StatusOK, serve(http.MethodGet, "/openapi.json", "").Code, "unversioned route")
+}
+
+func Test_server_streams(t *testing.T) {
+	h := New(slog.Default(), mux.NewRouter(), "", service.NewGreeter(),
+		WithCompression(middlewares.CompressionConfig{MinSize: 1}),
+		WithValidation(true),
+	)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	t.Run("sse", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/greet/stream?name=World&count=2&interval_ms=10", nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Empty(t, resp.Header.Get("Content-Encoding"), "event streams are not compressed")
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, "id: 1\nevent: greeting\ndata: {\"greeting\":\"Hello World\"}\n\n"+
+			"id: 2\nevent: greeting\ndata: {\"greeting\":\"Hello World\"}\n\n", string(body))
+
+		resp, err = http.Get(srv.URL + "/greet/stream?name=World&count=1000")
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("websocket", func(t *testing.T) {
+		ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/v1/greet/ws", nil)
+		require.NoError(t, err)
+		defer ws.Close()
+
+		for msg, want := range map[string]string{
+			`{"name":"World"}`: `{"greeting":"Hello World"}`,
+			`{"name":""}`:      `{"error":"name is required"}`,
+			`not json`:         `{"error":"invalid message"}`,
+		} {
+			require.NoError(t, ws.WriteMessage(websocket.TextMessage, []byte(msg)))
+			_, got, err := ws.ReadMessage()
+			require.NoError(t, err)
+			require.JSONEq(t, want, string(got), msg)
+		}
+	})
+}
+
+func Test_server_operations(t *testing.T) {
+	ctx := context.Background()
+	ops := operations.NewManager(slog.Default(), operations.NewMemoryStore(time.Hour))
+	var logs bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&logs, nil))
+	s := New(l, mux.NewRouter(), "", service.NewGreeter(), WithOperations(ops), WithValidation(true))
+	op, err := ops.Start(ctx, nil, func(context.Context, func(proto.Message) error) (proto.Message, error) {
+		return wrapperspb.String("done"), nil
+	})
+	require.NoError(t, err)
+	_, err = ops.WaitOperation(ctx, &longrunningpb.WaitOperationRequest{Name: op.Name})
+	require.NoError(t, err)
+
+	serve := func(method, url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(method, url, strings.NewReader(`{}`)))
+		return rec
+	}
+	rec := serve(http.MethodGet, "/v1/"+op.Name)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"name":"`+op.Name+`","done":true,"response":{"@type":"type.googleapis.com/google.protobuf.StringValue","value":"done"}}`, rec.Body.String())
+	require.Equal(t, "v1", rec.Header().Get(apiversion.Header))
+
+	rec = serve(http.MethodGet, "/operations?filter=done%20%3D%20true")
+	require.Equal(t, http.StatusOK, rec.Code, "selected version")
+	require.Contains(t, rec.Body.String(), op.Name)
+
+	rec = serve(http.MethodPost, "/v1/operations/unknown:cancel")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.JSONEq(t, `{"code":5,"message":"operation \"operations/unknown\" not found"}`, rec.Body.String())
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/v1/operations?filter=nope").Code)
+	require.NotContains(t, logs.String(), "level=WARN", "responses match the document")
+}
+
+type conflictingGreeter struct {
+	service.Greeter
+	conflict bool
+}
+
+func (g *conflictingGreeter) Greet(ctx context.Context, name string) (string, error) {
+	if g.conflict {
+		return "", service.ErrConflict
+	}
+	return g.Greeter.Greet(ctx, name)
+}
+
+type countingGreeter struct {
+	service.Greeter
+	calls int
+}
+
+func (g *countingGreeter) Greet(ctx context.Context, name string) (string, error) {
+	g.calls++
+	return g.Greeter.Greet(ctx, name)
 }
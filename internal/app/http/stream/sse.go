@@ -0,0 +1,159 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a server-sent event.
+type Event struct {
+	// ID is sent back by reconnecting clients in the Last-Event-ID header.
+	ID string
+	// Name is the event type, "message" when empty.
+	Name string
+	// Data is sent as it is when a string or []byte, JSON encoded otherwise.
+	Data any
+	// Retry tells clients how long to wait before reconnecting.
+	Retry time.Duration
+}
+
+// Events writes the events of one stream. Send is safe to call from several
+// goroutines.
+type Events struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	timeout time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+	err  error
+}
+
+// Send writes ev and flushes it to the client.
+func (e *Events) Send(ev Event) error {
+	var buf bytes.Buffer
+	if ev.ID != "" {
+		buf.WriteString("id: " + oneLine(ev.ID) + "\n")
+	}
+	if ev.Name != "" {
+		buf.WriteString("event: " + oneLine(ev.Name) + "\n")
+	}
+	if ev.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(ev.Retry.Milliseconds(), 10) + "\n")
+	}
+	var data []byte
+	switch d := ev.Data.(type) {
+	case nil:
+	case string:
+		data = []byte(d)
+	case []byte:
+		data = d
+	default:
+		var err error
+		if data, err = json.Marshal(d); err != nil {
+			return err
+		}
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		buf.WriteString("data: " + strings.TrimSuffix(line, "\r") + "\n")
+	}
+	buf.WriteString("\n")
+	return e.write(buf.Bytes())
+}
+
+func (e *Events) write(b []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		return e.err
+	}
+	_ = e.rc.SetWriteDeadline(time.Now().Add(e.timeout))
+	if _, err := e.w.Write(b); err != nil {
+		e.err = err
+		return err
+	}
+	if err := e.rc.Flush(); err != nil {
+		e.err = err
+		return err
+	}
+	e.last = time.Now()
+	return nil
+}
+
+// heartbeat sends a comment when nothing was sent for interval.
+func (e *Events) heartbeat(interval time.Duration) error {
+	e.mu.Lock()
+	idle := time.Since(e.last) >= interval
+	e.mu.Unlock()
+	if !idle {
+		return nil
+	}
+	return e.write([]byte(": ping\n\n"))
+}
+
+func oneLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// SSE serves h as an event stream. The context passed to h is done when the
+// client goes away or the server shuts down, h returns then. An error
+// returned by h is logged, the client only sees the stream end.
+func (s *Streams) SSE(h func(ctx context.Context, r *http.Request, events *Events) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, end, ok := s.begin(w, r, kindSSE)
+		if !ok {
+			return
+		}
+		defer end()
+
+		rc := http.NewResponseController(w)
+		// the server's WriteTimeout would end the stream, every write has
+		// its own deadline instead
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			s.l.Error("[HTTP] stream write deadline", slog.Any("error", err))
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// stops nginx from buffering the stream
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		events := &Events{w: w, rc: rc, timeout: s.cfg.WriteTimeout, last: time.Now()}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		hbCtx, stopHeartbeat := context.WithCancel(ctx)
+		heartbeatDone := make(chan struct{})
+		go func() {
+			defer close(heartbeatDone)
+			t := time.NewTicker(s.cfg.Heartbeat)
+			defer t.Stop()
+			for {
+				select {
+				case <-hbCtx.Done():
+					return
+				case <-t.C:
+					if err := events.heartbeat(s.cfg.Heartbeat); err != nil {
+						return
+					}
+				}
+			}
+		}()
+
+		err := h(ctx, r, events)
+		// nothing may be written once the handler returns
+		stopHeartbeat()
+		<-heartbeatDone
+		if err != nil && ctx.Err() == nil {
+			s.l.Error("[HTTP] event stream failed", slog.String("path", r.URL.Path), slog.Any("error", err))
+		}
+	})
+}
@@ -0,0 +1,157 @@
+// Package stream serves long-lived HTTP responses: server-sent events and
+// WebSockets. The handlers it returns lift the server's write timeout for
+// their route, keep idle connections alive with heartbeats, limit how many
+// streams are open and close them when the server shuts down.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	activeStreams = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_streams_active",
+		Help: "Number of open streams by kind (sse or websocket).",
+	}, []string{"kind"})
+	rejectedStreams = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_streams_rejected_total",
+		Help: "Number of streams rejected because MaxConns streams were open.",
+	}, []string{"kind"})
+)
+
+func init() {
+	prometheus.MustRegister(activeStreams, rejectedStreams)
+}
+
+const (
+	kindSSE       = "sse"
+	kindWebSocket = "websocket"
+)
+
+// Config configures the streams of a server.
+type Config struct {
+	// MaxConns limits the open streams, further ones get 503. 0 is
+	// unlimited.
+	MaxConns int
+	// Heartbeat is how often idle streams are pinged, so proxies keep them
+	// open and dead WebSocket peers are noticed. 15s by default.
+	Heartbeat time.Duration
+	// WriteTimeout bounds every write of a stream, which replaces the
+	// server's WriteTimeout for the whole response. 10s by default.
+	WriteTimeout time.Duration
+	// CheckOrigin accepts the Origin of WebSocket handshakes. By default only
+	// same-origin handshakes are accepted.
+	CheckOrigin func(r *http.Request) bool
+}
+
+// Streams tracks the open streams of a server.
+type Streams struct {
+	l       *slog.Logger
+	cfg     Config
+	slots   chan struct{}
+	closing chan struct{}
+	mu      sync.Mutex // orders wg.Add after Shutdown before Wait
+	wg      sync.WaitGroup
+}
+
+// New returns the streams of a server configured by cfg.
+func New(l *slog.Logger, cfg Config) *Streams {
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = 15 * time.Second
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 10 * time.Second
+	}
+	s := &Streams{l: l, cfg: cfg, closing: make(chan struct{})}
+	if cfg.MaxConns > 0 {
+		s.slots = make(chan struct{}, cfg.MaxConns)
+	}
+	return s
+}
+
+// Shutdown asks the open streams to close: event streams end and WebSockets
+// get a going-away close frame. New streams are rejected. It returns
+// immediately, Wait waits for the handlers. It is meant for
+// http.Server.RegisterOnShutdown, since Shutdown neither ends streaming
+// responses nor tracks hijacked connections.
+func (s *Streams) Shutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.shuttingDown() {
+		close(s.closing)
+	}
+}
+
+// Wait blocks until the handlers of all streams returned.
+func (s *Streams) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Streams) shuttingDown() bool {
+	select {
+	case <-s.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// begin takes a slot for a stream of kind, or answers 503 and returns false.
+// The returned context is done once the server shuts down or the request
+// ends, and end releases the slot.
+func (s *Streams) begin(w http.ResponseWriter, r *http.Request, kind string) (context.Context, func(), bool) {
+	if s.slots != nil {
+		select {
+		case s.slots <- struct{}{}:
+		default:
+			rejectedStreams.WithLabelValues(kind).Inc()
+			respondUnavailable(w, "too many open streams")
+			return nil, nil, false
+		}
+	}
+	s.mu.Lock()
+	if s.shuttingDown() {
+		s.mu.Unlock()
+		if s.slots != nil {
+			<-s.slots
+		}
+		respondUnavailable(w, "server is shutting down")
+		return nil, nil, false
+	}
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	activeStreams.WithLabelValues(kind).Inc()
+	ctx, cancel := context.WithCancel(r.Context())
+	go func() {
+		select {
+		case <-s.closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		cancel()
+		activeStreams.WithLabelValues(kind).Dec()
+		if s.slots != nil {
+			<-s.slots
+		}
+		s.wg.Done()
+	}, true
+}
+
+func respondUnavailable(w http.ResponseWriter, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_ = json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{Error: msg})
+}
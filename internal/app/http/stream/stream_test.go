@@ -0,0 +1,91 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreams_SSE(t *testing.T) {
+	s := New(slog.Default(), Config{MaxConns: 1, Heartbeat: 20 * time.Millisecond})
+	sent := make(chan struct{})
+	srv := httptest.NewServer(s.SSE(func(ctx context.Context, _ *http.Request, events *Events) error {
+		require.NoError(t, events.Send(Event{ID: "1", Name: "greeting", Data: map[string]string{"greeting": "Hello"}}))
+		require.NoError(t, events.Send(Event{Data: "two\nlines", Retry: time.Second}))
+		close(sent)
+		<-ctx.Done()
+		return nil
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	<-sent
+	rejected, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	rejected.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, rejected.StatusCode, "MaxConns streams are open")
+
+	r := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 10 {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	require.Equal(t, []string{
+		"id: 1", "event: greeting", `data: {"greeting":"Hello"}`, "",
+		"retry: 1000", "data: two", "data: lines", "",
+		": ping", "",
+	}, lines)
+
+	s.Shutdown()
+	s.Wait()
+	_, err = r.ReadString('\n')
+	require.Error(t, err, "stream ended on shutdown")
+}
+
+func TestStreams_WebSocket(t *testing.T) {
+	s := New(slog.Default(), Config{Heartbeat: time.Second})
+	srv := httptest.NewServer(s.WebSocket(func(ctx context.Context, _ *http.Request, conn *Conn) error {
+		for {
+			var msg map[string]string
+			if err := conn.ReadJSON(&msg); err != nil {
+				return err
+			}
+			if err := conn.WriteJSON(msg); err != nil {
+				return err
+			}
+		}
+	}))
+	defer srv.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer ws.Close()
+	require.NoError(t, ws.WriteJSON(map[string]string{"name": "World"}))
+	var got map[string]string
+	require.NoError(t, ws.ReadJSON(&got))
+	require.Equal(t, map[string]string{"name": "World"}, got)
+
+	s.Shutdown()
+	_, _, err = ws.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+	s.Wait()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "shutting down")
+}
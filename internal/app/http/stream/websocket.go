@@ -0,0 +1,109 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Conn is a WebSocket connection. One goroutine may read while others
+// write.
+type Conn struct {
+	ws      *websocket.Conn
+	timeout time.Duration
+	mu      sync.Mutex
+}
+
+// ReadJSON reads the next message into v.
+func (c *Conn) ReadJSON(v any) error {
+	return c.ws.ReadJSON(v)
+}
+
+// WriteJSON sends v as a text message.
+func (c *Conn) WriteJSON(v any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.ws.SetWriteDeadline(time.Now().Add(c.timeout))
+	return c.ws.WriteJSON(v)
+}
+
+// close sends a close frame, the peer answers with its own, which ends a
+// blocked read, or the read deadline does.
+func (c *Conn) close(code int, reason string) {
+	deadline := time.Now().Add(c.timeout)
+	_ = c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	_ = c.ws.SetReadDeadline(deadline)
+}
+
+// WebSocket upgrades the request and serves the connection with h. The
+// context passed to h is done when the server shuts down; the connection
+// then gets a going-away close frame, so a blocked read returns. Peers are
+// pinged every heartbeat and dropped when they stop answering. An error
+// returned by h is logged and the peer gets an internal error close frame.
+func (s *Streams) WebSocket(h func(ctx context.Context, r *http.Request, conn *Conn) error) http.Handler {
+	upgrader := websocket.Upgrader{
+		HandshakeTimeout: s.cfg.WriteTimeout,
+		CheckOrigin:      s.cfg.CheckOrigin,
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, end, ok := s.begin(w, r, kindWebSocket)
+		if !ok {
+			return
+		}
+		defer end()
+
+		// the upgrader answers failed handshakes itself
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		conn := &Conn{ws: ws, timeout: s.cfg.WriteTimeout}
+
+		alive := func() { _ = ws.SetReadDeadline(time.Now().Add(2 * s.cfg.Heartbeat)) }
+		alive()
+		ws.SetPongHandler(func(string) error {
+			alive()
+			return nil
+		})
+
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			t := time.NewTicker(s.cfg.Heartbeat)
+			defer t.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ctx.Done():
+					if s.shuttingDown() {
+						conn.close(websocket.CloseGoingAway, "server is shutting down")
+					}
+					return
+				case <-t.C:
+					_ = ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.cfg.WriteTimeout))
+				}
+			}
+		}()
+
+		err = h(ctx, r, conn)
+		var closeErr *websocket.CloseError
+		switch {
+		case err == nil:
+			conn.close(websocket.CloseNormalClosure, "")
+		case errors.As(err, &closeErr), errors.Is(err, os.ErrDeadlineExceeded), ctx.Err() != nil:
+			// the peer left or stopped answering pings, or the server is
+			// shutting down
+		default:
+			s.l.Error("[HTTP] websocket failed", slog.String("path", r.URL.Path), slog.Any("error", err))
+			conn.close(websocket.CloseInternalServerErr, http.StatusText(http.StatusInternalServerError))
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package infra
+
+import (
+	"crypto/subtle"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	rpprof "runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DebugConfig configures the diagnostics endpoints.
+type DebugConfig struct {
+	// Enabled exposes pprof, expvar, goroutine dumps and trace capture.
+	Enabled bool
+	// Token is the bearer token required by every diagnostics request.
+	Token string
+	// MaxDuration bounds the seconds parameter of profile and trace captures.
+	MaxDuration time.Duration
+}
+
+func (s *Server) debugRoutes(cfg DebugConfig) {
+	// httpinfra serves pprof without authentication, so /debug/ is always
+	// claimed here and only ever reachable with a valid token.
+	if !cfg.Enabled {
+		s.router.PathPrefix("/debug/").HandlerFunc(http.NotFound)
+		return
+	}
+
+	r := s.router.PathPrefix("/debug/").Subrouter()
+	r.Use(requireToken(cfg.Token))
+	r.HandleFunc("/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/pprof/symbol", pprof.Symbol)
+	r.Handle("/pprof/profile", limitSeconds(cfg.MaxDuration, 30*time.Second, http.HandlerFunc(pprof.Profile)))
+	r.Handle("/pprof/trace", limitSeconds(cfg.MaxDuration, time.Second, http.HandlerFunc(pprof.Trace)))
+	r.PathPrefix("/pprof/").HandlerFunc(pprof.Index)
+	r.Handle("/vars", expvar.Handler())
+	r.HandleFunc("/goroutines", handleGoroutines)
+}
+
+// requireToken rejects requests without a matching "Authorization: Bearer" header.
+func requireToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="debug"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitSeconds clamps the "seconds" query parameter of a capture endpoint to
+// maxDuration. def is the default the wrapped handler uses when it is unset.
+// Whole seconds are used because pprof.Profile does not accept fractions.
+func limitSeconds(maxDuration, def time.Duration, next http.Handler) http.Handler {
+	limit := max(int64(maxDuration/time.Second), 1)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		sec, err := strconv.ParseInt(q.Get("seconds"), 10, 64)
+		if err != nil || sec <= 0 {
+			sec = max(int64(def/time.Second), 1)
+		}
+		q.Set("seconds", strconv.FormatInt(min(sec, limit), 10))
+		r.URL.RawQuery = q.Encode()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleGoroutines writes the stack traces of all goroutines in plain text.
+func handleGoroutines(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_ = rpprof.Lookup("goroutine").WriteTo(w, 2)
+}
@@ -0,0 +1,92 @@
+package infra
+
+import (
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+var startTime = time.Now()
+
+// BuildInfo describes the running binary.
+type BuildInfo struct {
+	Version   string
+	Revision  string
+	GoVersion string
+	Modified  bool
+}
+
+// ReadBuildInfo combines the ldflags injected version with the VCS stamp
+// recorded by the Go toolchain.
+func ReadBuildInfo(version string) BuildInfo {
+	bi, _ := debug.ReadBuildInfo()
+	return newBuildInfo(version, bi)
+}
+
+func newBuildInfo(version string, bi *debug.BuildInfo) BuildInfo {
+	info := BuildInfo{Version: version, Revision: "unknown", GoVersion: "unknown"}
+	if bi == nil {
+		return info
+	}
+	info.GoVersion = bi.GoVersion
+	if info.Version == "" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.modified":
+			info.Modified, _ = strconv.ParseBool(s.Value)
+		}
+	}
+	return info
+}
+
+// RegisterMetrics registers build and runtime metrics on reg:
+//
+//   - app_build_info{version,revision,goversion,modified} always 1
+//   - app_start_time_seconds
+//   - go_sched_* and go_gc_* from runtime/metrics, which include the
+//     GOMAXPROCS (go_sched_gomaxprocs_threads) and GOMEMLIMIT
+//     (go_gc_gomemlimit_bytes) values in effect, GC pause and scheduler
+//     latency histograms.
+//
+// The default Go collector is replaced, so reg must be the registry it was
+// registered on (prometheus.DefaultRegisterer for the infra /metrics).
+func RegisterMetrics(reg prometheus.Registerer, info BuildInfo) error {
+	buildInfo := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "app",
+		Name:      "build_info",
+		Help:      "Build information of the running binary.",
+		ConstLabels: prometheus.Labels{
+			"version":   info.Version,
+			"revision":  info.Revision,
+			"goversion": info.GoVersion,
+			"modified":  strconv.FormatBool(info.Modified),
+		},
+	})
+	buildInfo.Set(1)
+
+	start := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "app",
+		Name:      "start_time_seconds",
+		Help:      "Start time of the application since unix epoch in seconds.",
+	})
+	start.Set(float64(startTime.Unix()))
+
+	reg.Unregister(collectors.NewGoCollector())
+	goCollector := collectors.NewGoCollector(
+		collectors.WithGoCollectorRuntimeMetrics(collectors.MetricsGC, collectors.MetricsScheduler),
+	)
+
+	for _, c := range []prometheus.Collector{buildInfo, start, goCollector} {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package infra
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadBuildInfo(t *testing.T) {
+	bi := &debug.BuildInfo{
+		GoVersion: "go1.23.0",
+		Main:      debug.Module{Version: "(devel)"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abc123"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+	require.Equal(t, BuildInfo{Version: "v1.0.0", Revision: "abc123", GoVersion: "go1.23.0", Modified: true}, newBuildInfo("v1.0.0", bi))
+	require.Equal(t, "(devel)", newBuildInfo("", bi).Version)
+	require.Equal(t, BuildInfo{Revision: "unknown", GoVersion: "unknown"}, newBuildInfo("", nil))
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	require.NoError(t, RegisterMetrics(reg, BuildInfo{Version: "v1.0.0", Revision: "abc123", GoVersion: "go1.23.0"}))
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	names := map[string]bool{}
+	for _, mf := range mfs {
+		names[mf.GetName()] = true
+	}
+	for _, name := range []string{
+		"app_build_info",
+		"app_start_time_seconds",
+		"go_sched_gomaxprocs_threads",
+		"go_gc_gomemlimit_bytes",
+		"go_sched_latencies_seconds",
+	} {
+		require.True(t, names[name], name)
+	}
+}
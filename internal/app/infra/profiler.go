@@ -0,0 +1,104 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sort"
+	"time"
+)
+
+// Profiler periodically writes CPU and heap profiles to a directory so that
+// a spike can be inspected after the fact without redeploying.
+type Profiler struct {
+	l        *slog.Logger
+	dir      string
+	interval time.Duration
+	duration time.Duration
+	keep     int
+}
+
+// NewProfiler returns a profiler that every interval captures a CPU profile
+// for duration and a heap snapshot, keeping the newest keep files of each kind.
+func NewProfiler(l *slog.Logger, dir string, interval, duration time.Duration, keep int) *Profiler {
+	return &Profiler{l: l, dir: dir, interval: interval, duration: min(duration, interval), keep: keep}
+}
+
+func (p *Profiler) Run(ctx context.Context) error {
+	if err := os.MkdirAll(p.dir, 0o750); err != nil {
+		return fmt.Errorf("create profile dir: %w", err)
+	}
+	p.l.Info("[PROFILER] continuous profiling started", slog.String("dir", p.dir), slog.Duration("interval", p.interval))
+
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			p.l.Info("[PROFILER] continuous profiling stopped")
+			return nil
+		case <-t.C:
+			p.capture(ctx)
+		}
+	}
+}
+
+func (p *Profiler) capture(ctx context.Context) {
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	if err := p.writeCPU(ctx, filepath.Join(p.dir, "cpu-"+stamp+".pprof")); err != nil {
+		p.l.Warn("[PROFILER] cpu profile failed", slog.Any("error", err))
+	}
+	if err := p.writeHeap(filepath.Join(p.dir, "heap-"+stamp+".pprof")); err != nil {
+		p.l.Warn("[PROFILER] heap profile failed", slog.Any("error", err))
+	}
+	for _, kind := range []string{"cpu", "heap"} {
+		p.prune(kind)
+	}
+}
+
+func (p *Profiler) writeCPU(ctx context.Context, name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// fails when a capture through /debug/pprof/profile is in progress
+	if err = pprof.StartCPUProfile(f); err != nil {
+		_ = os.Remove(name)
+		return err
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(p.duration):
+	}
+	pprof.StopCPUProfile()
+	return nil
+}
+
+func (p *Profiler) writeHeap(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return pprof.Lookup("heap").WriteTo(f, 0)
+}
+
+// prune removes the oldest profiles of a kind beyond the retention count.
+// File names embed a sortable timestamp, so lexical order is age order.
+func (p *Profiler) prune(kind string) {
+	files, err := filepath.Glob(filepath.Join(p.dir, kind+"-*.pprof"))
+	if err != nil || len(files) <= p.keep {
+		return
+	}
+	sort.Strings(files)
+	for _, f := range files[:len(files)-p.keep] {
+		if err = os.Remove(f); err != nil {
+			p.l.Warn("[PROFILER] remove old profile failed", slog.String("file", f), slog.Any("error", err))
+		}
+	}
+}
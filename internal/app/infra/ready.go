@@ -0,0 +1,48 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Check reports whether a dependency is ready to serve traffic.
+type Check func(ctx context.Context) error
+
+// AddCheck registers a readiness check served on /readyz.
+func (s *Server) AddCheck(name string, check Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks[name] = check
+}
+
+// handleReady runs all readiness checks and responds 503 with the failures
+// if any of them fails, 200 otherwise.
+func (s *Server) handleReady() http.HandlerFunc {
+	type response struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks,omitempty"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		s.mu.RLock()
+		failed := map[string]string{}
+		for name, check := range s.checks {
+			if err := check(ctx); err != nil {
+				failed[name] = err.Error()
+			}
+		}
+		s.mu.RUnlock()
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if len(failed) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(w).Encode(response{Status: "unavailable", Checks: failed})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(response{Status: "ok"})
+	}
+}
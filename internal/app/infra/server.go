@@ -0,0 +1,67 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Server is the infrastructure HTTP server. It serves the base handler
+// (metrics, healthz, info from httpinfra), the /readyz readiness endpoint
+// and, when enabled, the token protected diagnostics endpoints under /debug/.
+type Server struct {
+	l      *slog.Logger
+	router *mux.Router
+	srv    *http.Server
+
+	mu     sync.RWMutex
+	checks map[string]Check
+}
+
+func New(l *slog.Logger, base http.Handler, addr string, debug DebugConfig) *Server {
+	s := &Server{l: l, router: mux.NewRouter(), checks: map[string]Check{}}
+	s.router.HandleFunc("/readyz", s.handleReady()).Methods(http.MethodGet)
+	s.debugRoutes(debug)
+	s.router.PathPrefix("/").Handler(base)
+
+	writeTimeout := 10 * time.Second
+	if debug.Enabled {
+		// profile and trace captures stream for up to MaxDuration
+		writeTimeout += debug.MaxDuration
+	}
+	s.srv = &http.Server{
+		Addr:         addr,
+		Handler:      s,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: writeTimeout,
+		IdleTimeout:  20 * time.Second,
+	}
+	return s
+}
+
+func (s *Server) Run(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		s.l.Info("[INFRA-HTTP] server stopping", slog.String("addr", s.srv.Addr))
+		if err := s.srv.Shutdown(context.WithoutCancel(ctx)); err != nil {
+			s.l.Error("[INFRA-HTTP] server shutdown error", slog.Any("error", err))
+		}
+	}()
+	s.l.Info("[INFRA-HTTP] server listening", slog.String("addr", s.srv.Addr))
+	if err := s.srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	<-stopped
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
@@ -0,0 +1,86 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_server(t *testing.T) {
+	base := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("base"))
+	})
+	do := func(h http.Handler, url, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		s := New(slog.Default(), base, "", DebugConfig{})
+		require.Equal(t, http.StatusNotFound, do(s, "/debug/pprof/", "").Code)
+		require.Equal(t, http.StatusNotFound, do(s, "/debug/vars", "secret").Code)
+		require.Equal(t, "base", do(s, "/healthz", "").Body.String())
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		s := New(slog.Default(), base, "", DebugConfig{Enabled: true, Token: "secret", MaxDuration: time.Second})
+		require.Equal(t, http.StatusUnauthorized, do(s, "/debug/vars", "").Code)
+		require.Equal(t, http.StatusUnauthorized, do(s, "/debug/vars", "wrong").Code)
+		require.Equal(t, http.StatusOK, do(s, "/debug/vars", "secret").Code)
+		require.Equal(t, http.StatusOK, do(s, "/debug/pprof/", "secret").Code)
+
+		rec := do(s, "/debug/goroutines", "secret")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Contains(t, rec.Body.String(), "goroutine ")
+
+		require.Equal(t, "base", do(s, "/metrics", "").Body.String())
+	})
+
+	t.Run("empty token rejects everything", func(t *testing.T) {
+		s := New(slog.Default(), base, "", DebugConfig{Enabled: true, MaxDuration: time.Second})
+		req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+		req.Header.Set("Authorization", "Bearer ")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func Test_server_ready(t *testing.T) {
+	s := New(slog.Default(), http.NotFoundHandler(), "", DebugConfig{})
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, get().Code)
+
+	s.AddCheck("ok", func(context.Context) error { return nil })
+	s.AddCheck("db", func(context.Context) error { return errors.New("connection refused") })
+	rec := get()
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.JSONEq(t, `{"status":"unavailable","checks":{"db":"connection refused"}}`, rec.Body.String())
+}
+
+func Test_limitSeconds(t *testing.T) {
+	var got string
+	h := limitSeconds(5*time.Second, 30*time.Second, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query().Get("seconds")
+	}))
+	for query, want := range map[string]string{"": "5", "?seconds=2": "2", "?seconds=600": "5", "?seconds=0.5": "5"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/debug/pprof/profile"+query, nil))
+		require.Equal(t, want, got, query)
+	}
+}
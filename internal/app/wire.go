@@ -0,0 +1,220 @@
+// Package app wires the application components together.
+package app
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ravilushqa/boilerplate/internal/app/grpc"
+	"github.com/ravilushqa/boilerplate/internal/app/http"
+	"github.com/ravilushqa/boilerplate/internal/app/http/middlewares"
+	"github.com/ravilushqa/boilerplate/internal/app/http/stream"
+	"github.com/ravilushqa/boilerplate/internal/di"
+	"github.com/ravilushqa/boilerplate/internal/idempotency"
+	"github.com/ravilushqa/boilerplate/internal/jobs"
+	"github.com/ravilushqa/boilerplate/internal/listing"
+	"github.com/ravilushqa/boilerplate/internal/messaging"
+	"github.com/ravilushqa/boilerplate/internal/operations"
+	"github.com/ravilushqa/boilerplate/internal/outbox"
+	"github.com/ravilushqa/boilerplate/internal/recovery"
+	"github.com/ravilushqa/boilerplate/internal/service"
+	"github.com/ravilushqa/boilerplate/internal/storage"
+)
+
+// Config holds the settings the components are built from.
+type Config struct {
+	HTTPAddress string
+	GRPCAddress string
+	GRPCOptions []grpc.Option
+	// IdempotencyTTL is how long responses to requests with an idempotency
+	// key are replayed.
+	IdempotencyTTL time.Duration
+	// PageTokenSecret signs list page tokens, a random key is used when
+	// empty. PageTokenTTL is how long they stay valid, 0 for forever.
+	PageTokenSecret string
+	PageTokenTTL    time.Duration
+	// OperationsTTL is how long done long-running operations are kept.
+	OperationsTTL time.Duration
+	// HTTPCacheSize is the number of responses kept by the HTTP response
+	// cache, 0 disables it.
+	HTTPCacheSize int
+	// HTTPCompressionMinSize is the smallest HTTP response compressed,
+	// negative disables compression.
+	HTTPCompressionMinSize int
+	// HTTPValidateRequests rejects HTTP requests not matching the OpenAPI
+	// document, HTTPValidateResponses logs responses not matching it.
+	HTTPValidateRequests  bool
+	HTTPValidateResponses bool
+	// HTTPStreams configures event streams and WebSockets.
+	HTTPStreams stream.Config
+	// Jobs configures the pool running background jobs.
+	Jobs jobs.PoolConfig
+	// Outbox configures the relay publishing the outbox.
+	Outbox outbox.RelayConfig
+	// Storage configures the database, state is kept in memory when its DSN
+	// is empty. StorageMigrate applies the pending migrations on start.
+	Storage        storage.Config
+	StorageMigrate bool
+}
+
+// Provide registers the constructors of the transport servers and their
+// dependencies. New services, repositories and clients are registered here
+// and resolved by the constructors that need them.
+func Provide(c *di.Container, l *slog.Logger, cfg Config) {
+	di.Supply(c, l)
+	di.Supply(c, cfg)
+
+	di.Provide(c, func(c *di.Container) (*recovery.Handler, error) {
+		// a recovery.Reporter (e.g. a Sentry adapter) is passed here
+		return recovery.New(di.MustResolve[*slog.Logger](c), nil), nil
+	})
+	di.Provide(c, func(c *di.Container) (idempotency.Store, error) {
+		// a shared store (e.g. Redis) is needed once there are several replicas
+		return idempotency.NewMemoryStore(di.MustResolve[Config](c).IdempotencyTTL), nil
+	})
+	di.Provide(c, func(c *di.Container) (*storage.DB, error) {
+		cfg := di.MustResolve[Config](c)
+		if cfg.Storage.DSN == "" {
+			return nil, nil
+		}
+		var opts []storage.Option
+		if cfg.StorageMigrate {
+			opts = append(opts, storage.WithMigrations(storage.Migrations()))
+		}
+		return storage.Open(di.MustResolve[*slog.Logger](c), cfg.Storage, opts...)
+	})
+	di.Provide(c, func(c *di.Container) (service.Greeter, error) {
+		cfg := di.MustResolve[Config](c)
+		tokens := listing.NewTokens([]byte(cfg.PageTokenSecret), cfg.PageTokenTTL)
+		opts := []service.GreeterOption{service.WithPageTokens(tokens)}
+		if db := di.MustResolve[*storage.DB](c); db != nil {
+			// each greeting is stored with its event, published by the relay
+			greetings := storage.NewGreetings(db.DB, storage.WithEvents(
+				di.MustResolve[*outbox.SQLStore](c),
+				di.MustResolve[*outbox.Relay](c),
+			))
+			opts = append(opts, service.WithRepository(greetings))
+		}
+		return service.NewGreeter(opts...), nil
+	})
+	// a Kafka or NATS driver replaces the in-process broker once events
+	// cross services
+	di.Provide(c, func(c *di.Container) (*messaging.MemoryBroker, error) {
+		return messaging.NewMemoryBroker(), nil
+	})
+	di.Provide(c, func(c *di.Container) (messaging.Publisher, error) {
+		return messaging.TracingPublisher(di.MustResolve[*messaging.MemoryBroker](c)), nil
+	})
+	di.Provide(c, func(c *di.Container) (messaging.Subscriber, error) {
+		return di.MustResolve[*messaging.MemoryBroker](c), nil
+	})
+	di.Provide(c, func(c *di.Container) (*outbox.MemoryStore, error) {
+		return outbox.NewMemoryStore(), nil
+	})
+	di.Provide(c, func(c *di.Container) (*outbox.SQLStore, error) {
+		// repositories writing to the database enqueue their events with its
+		// Enqueue, in the transaction writing their state, e.g. Greetings
+		db := di.MustResolve[*storage.DB](c)
+		if db == nil {
+			return nil, nil
+		}
+		return outbox.NewSQLStore(db.DB), nil
+	})
+	di.Provide(c, func(c *di.Container) (outbox.Store, error) {
+		if s := di.MustResolve[*outbox.SQLStore](c); s != nil {
+			return s, nil
+		}
+		return di.MustResolve[*outbox.MemoryStore](c), nil
+	})
+	di.Provide(c, func(c *di.Container) (*outbox.Relay, error) {
+		return outbox.NewRelay(
+			di.MustResolve[*slog.Logger](c),
+			di.MustResolve[outbox.Store](c),
+			di.MustResolve[messaging.Publisher](c),
+			di.MustResolve[Config](c).Outbox,
+		), nil
+	})
+	di.Provide(c, func(c *di.Container) ([]*messaging.Consumer, error) {
+		// consumers are added here, their handlers wrapped in the middleware,
+		// e.g.
+		// messaging.NewConsumer(l, sub, "orders", "billing", h, messaging.WithMiddleware(
+		//	messaging.Tracing(), messaging.Metrics(), messaging.Logging(l),
+		//	messaging.DeadLetter(pub, "orders.dead"), messaging.Retry(3, time.Second),
+		//	messaging.Recover(di.MustResolve[*recovery.Handler](c)),
+		// ))
+		return nil, nil
+	})
+	di.Provide(c, func(c *di.Container) (*jobs.Pool, error) {
+		return jobs.NewPool(
+			di.MustResolve[*slog.Logger](c),
+			di.MustResolve[Config](c).Jobs,
+			jobs.WithRecovery(di.MustResolve[*recovery.Handler](c)),
+		), nil
+	})
+	di.Provide(c, func(c *di.Container) (*jobs.Scheduler, error) {
+		s := jobs.NewScheduler(
+			di.MustResolve[*slog.Logger](c),
+			jobs.WithRecovery(di.MustResolve[*recovery.Handler](c)),
+		)
+		// scheduled jobs are added here, e.g.
+		// s.Add("cleanup", "@hourly", repo.Cleanup, jobs.Singleton())
+		return s, nil
+	})
+	di.Provide(c, func(c *di.Container) (*operations.Manager, error) {
+		cfg := di.MustResolve[Config](c)
+		// a shared store (e.g. a database table) is needed once there are
+		// several replicas
+		return operations.NewManager(
+			di.MustResolve[*slog.Logger](c),
+			operations.NewMemoryStore(cfg.OperationsTTL),
+			operations.WithPageTokens(listing.NewTokens([]byte(cfg.PageTokenSecret), cfg.PageTokenTTL)),
+			operations.WithRecovery(di.MustResolve[*recovery.Handler](c)),
+		), nil
+	})
+
+	di.Provide(c, func(c *di.Container) (*mux.Router, error) {
+		return mux.NewRouter(), nil
+	})
+	di.Provide(c, func(c *di.Container) (*http.Server, error) {
+		cfg := di.MustResolve[Config](c)
+		opts := []http.Option{
+			http.WithRecovery(di.MustResolve[*recovery.Handler](c)),
+			http.WithIdempotency(di.MustResolve[idempotency.Store](c)),
+			http.WithStreams(cfg.HTTPStreams),
+			http.WithOperations(di.MustResolve[*operations.Manager](c)),
+		}
+		if cfg.HTTPCompressionMinSize >= 0 {
+			opts = append(opts, http.WithCompression(middlewares.CompressionConfig{MinSize: cfg.HTTPCompressionMinSize}))
+		}
+		if cfg.HTTPValidateRequests {
+			opts = append(opts, http.WithValidation(cfg.HTTPValidateResponses))
+		}
+		if cfg.HTTPCacheSize > 0 {
+			opts = append(opts, http.WithResponseCache(middlewares.NewResponseCache(cfg.HTTPCacheSize)))
+		}
+		return http.New(
+			di.MustResolve[*slog.Logger](c),
+			di.MustResolve[*mux.Router](c),
+			cfg.HTTPAddress,
+			di.MustResolve[service.Greeter](c),
+			opts...,
+		), nil
+	})
+	di.Provide(c, func(c *di.Container) (*grpc.Server, error) {
+		return grpc.New(
+			di.MustResolve[*slog.Logger](c),
+			di.MustResolve[Config](c).GRPCAddress,
+			di.MustResolve[service.Greeter](c),
+			append(
+				[]grpc.Option{
+					grpc.WithRecovery(di.MustResolve[*recovery.Handler](c)),
+					grpc.WithIdempotency(di.MustResolve[idempotency.Store](c)),
+					grpc.WithOperations(di.MustResolve[*operations.Manager](c)),
+				},
+				di.MustResolve[Config](c).GRPCOptions...,
+			)...,
+		), nil
+	})
+}
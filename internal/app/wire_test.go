@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ravilushqa/boilerplate/internal/app/grpc"
+	"github.com/ravilushqa/boilerplate/internal/app/http"
+	"github.com/ravilushqa/boilerplate/internal/di"
+	"github.com/ravilushqa/boilerplate/internal/outbox"
+	"github.com/ravilushqa/boilerplate/internal/service"
+	"github.com/ravilushqa/boilerplate/internal/storage"
+)
+
+func TestProvide(t *testing.T) {
+	c := di.New()
+	Provide(c, slog.Default(), Config{HTTPAddress: ":0", GRPCAddress: ":0"})
+
+	router := mux.NewRouter()
+	di.Override(c, func(*di.Container) (*mux.Router, error) { return router, nil })
+
+	_, err := di.Resolve[*http.Server](c)
+	require.NoError(t, err)
+	_, err = di.Resolve[*grpc.Server](c)
+	require.NoError(t, err)
+
+	// the HTTP server registered its routes on the overridden router
+	var paths []string
+	_ = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, _ := route.GetPathTemplate()
+		paths = append(paths, tpl)
+		return nil
+	})
+	require.Contains(t, paths, "/v1/greet")
+}
+
+func TestProvide_storage(t *testing.T) {
+	ctx := context.Background()
+	c := di.New()
+	Provide(c, slog.Default(), Config{
+		Storage:        storage.Config{Driver: "sqlite3", DSN: filepath.Join(t.TempDir(), "app.db")},
+		StorageMigrate: true,
+	})
+	db := di.MustResolve[*storage.DB](c)
+	require.NoError(t, db.Start(ctx))
+	defer func() { _ = db.Stop(ctx) }()
+
+	// a greeting is stored with its event, relayed from the database
+	store, ok := di.MustResolve[outbox.Store](c).(*outbox.SQLStore)
+	require.True(t, ok)
+	_, err := di.MustResolve[service.Greeter](c).Greet(ctx, "World")
+	require.NoError(t, err)
+	events, err := store.Pending(ctx, time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, storage.GreetingsTopic, events[0].Message.Topic)
+
+	// without a DSN state is kept in memory
+	c = di.New()
+	Provide(c, slog.Default(), Config{})
+	require.Nil(t, di.MustResolve[*storage.DB](c))
+	require.IsType(t, &outbox.MemoryStore{}, di.MustResolve[outbox.Store](c))
+}
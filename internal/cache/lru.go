@@ -0,0 +1,93 @@
+// Package cache is an in-process LRU cache whose entries expire after a TTL.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type item[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// LRU holds up to a fixed number of entries, dropping the least recently
+// used one when full. It is safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	now      func() time.Time
+	ll       *list.List
+	items    map[K]*list.Element
+}
+
+// New returns a cache holding up to capacity entries.
+func New[K comparable, V any](capacity int) *LRU[K, V] {
+	return &LRU[K, V]{
+		capacity: capacity,
+		now:      time.Now,
+		ll:       list.New(),
+		items:    map[K]*list.Element{},
+	}
+}
+
+// Get returns the value of key unless it is missing or expired.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var zero V
+	e, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	it := e.Value.(*item[K, V])
+	if !c.now().Before(it.expires) {
+		c.remove(e)
+		return zero, false
+	}
+	c.ll.MoveToFront(e)
+	return it.value, true
+}
+
+// Set stores value under key for ttl. It reports whether another entry was
+// evicted to make room.
+func (c *LRU[K, V]) Set(key K, value V, ttl time.Duration) (evicted bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(ttl)
+	if e, ok := c.items[key]; ok {
+		it := e.Value.(*item[K, V])
+		it.value, it.expires = value, expires
+		c.ll.MoveToFront(e)
+		return false
+	}
+	c.items[key] = c.ll.PushFront(&item[K, V]{key: key, value: value, expires: expires})
+	if c.ll.Len() > c.capacity {
+		c.remove(c.ll.Back())
+		return true
+	}
+	return false
+}
+
+// Delete removes key.
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
+}
+
+// Len returns the number of entries, including expired ones not yet dropped.
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LRU[K, V]) remove(e *list.Element) {
+	c.ll.Remove(e)
+	delete(c.items, e.Value.(*item[K, V]).key)
+}
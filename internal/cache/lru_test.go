@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Now()
+	c := New[string, int](2)
+	c.now = func() time.Time { return now }
+
+	require.False(t, c.Set("a", 1, time.Minute))
+	require.False(t, c.Set("b", 2, time.Minute))
+	_, _ = c.Get("a")
+	require.True(t, c.Set("c", 3, time.Minute), "b is the least recently used")
+
+	_, ok := c.Get("b")
+	require.False(t, ok)
+	v, ok := c.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 1, v)
+
+	c.Set("a", 10, time.Second)
+	now = now.Add(2 * time.Second)
+	_, ok = c.Get("a")
+	require.False(t, ok, "expired")
+	require.Equal(t, 1, c.Len())
+
+	c.Delete("c")
+	require.Equal(t, 0, c.Len())
+}
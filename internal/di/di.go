@@ -0,0 +1,146 @@
+// Package di is a small typed dependency container. Constructors are
+// registered per type with Provide and invoked lazily, once, on the first
+// Resolve of that type; tests replace individual constructors with Override
+// before resolving. Resolution is meant to happen while wiring the
+// application, from a single goroutine.
+package di
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+type provider struct {
+	ctor func(*Container) (any, error)
+}
+
+// Container holds constructors and the singletons built from them.
+type Container struct {
+	mu        sync.Mutex
+	providers map[reflect.Type]provider
+	instances map[reflect.Type]any
+	// resolving is the chain of types being built, used to report cycles.
+	resolving []reflect.Type
+}
+
+func New() *Container {
+	return &Container{
+		providers: map[reflect.Type]provider{},
+		instances: map[reflect.Type]any{},
+	}
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Provide registers the constructor of T. It panics if T already has one,
+// use Override to replace it.
+func Provide[T any](c *Container, ctor func(*Container) (T, error)) {
+	t := typeOf[T]()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.providers[t]; ok {
+		panic(fmt.Sprintf("di: %s already provided", t))
+	}
+	c.providers[t] = provider{ctor: func(c *Container) (any, error) { return ctor(c) }}
+}
+
+// Supply registers an already built value of T.
+func Supply[T any](c *Container, v T) {
+	Provide(c, func(*Container) (T, error) { return v, nil })
+}
+
+// Override replaces the constructor of T and drops an instance already built
+// from the previous one. Values that were built from the old T are not
+// rebuilt, so override before resolving dependents.
+func Override[T any](c *Container, ctor func(*Container) (T, error)) {
+	t := typeOf[T]()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.providers[t] = provider{ctor: func(c *Container) (any, error) { return ctor(c) }}
+	delete(c.instances, t)
+}
+
+// Resolve returns the instance of T, building it and its dependencies on
+// first use. An interface T whose provider returned nil resolves to nil.
+func Resolve[T any](c *Container) (T, error) {
+	var zero T
+	v, err := c.resolve(typeOf[T]())
+	if err != nil {
+		return zero, err
+	}
+	// a nil interface is stored as an untyped nil
+	t, _ := v.(T)
+	return t, nil
+}
+
+// resolveError carries a MustResolve failure out of a constructor.
+type resolveError struct{ err error }
+
+// MustResolve is like Resolve but panics on error. Inside a constructor the
+// panic is turned back into an error returned by the outer Resolve.
+func MustResolve[T any](c *Container) T {
+	v, err := Resolve[T](c)
+	if err != nil {
+		panic(resolveError{err})
+	}
+	return v
+}
+
+func (c *Container) resolve(t reflect.Type) (any, error) {
+	c.mu.Lock()
+	if v, ok := c.instances[t]; ok {
+		c.mu.Unlock()
+		return v, nil
+	}
+	p, ok := c.providers[t]
+	if !ok {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("di: no provider for %s", t)
+	}
+	for _, r := range c.resolving {
+		if r == t {
+			chain := c.chain(t)
+			c.mu.Unlock()
+			return nil, fmt.Errorf("di: dependency cycle %s", chain)
+		}
+	}
+	c.resolving = append(c.resolving, t)
+	c.mu.Unlock()
+
+	// constructors resolve their own dependencies, so the lock is not held
+	v, err := build(c, p)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resolving = c.resolving[:len(c.resolving)-1]
+	if err != nil {
+		return nil, fmt.Errorf("di: build %s: %w", t, err)
+	}
+	c.instances[t] = v
+	return v, nil
+}
+
+func build(c *Container, p provider) (v any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			re, ok := r.(resolveError)
+			if !ok {
+				panic(r)
+			}
+			err = re.err
+		}
+	}()
+	return p.ctor(c)
+}
+
+func (c *Container) chain(t reflect.Type) string {
+	names := make([]string, 0, len(c.resolving)+1)
+	for _, r := range c.resolving {
+		names = append(names, r.String())
+	}
+	return strings.Join(append(names, t.String()), " -> ")
+}
@@ -0,0 +1,97 @@
+package di
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	store   interface{ Name() string }
+	repo    struct{ name string }
+	service struct{ repo *repo }
+	a       struct{}
+	b       struct{}
+)
+
+func TestContainer(t *testing.T) {
+	t.Run("resolve once", func(t *testing.T) {
+		c := New()
+		calls := 0
+		Provide(c, func(*Container) (*repo, error) {
+			calls++
+			return &repo{name: "sql"}, nil
+		})
+		Provide(c, func(c *Container) (*service, error) {
+			return &service{repo: MustResolve[*repo](c)}, nil
+		})
+
+		s1, err := Resolve[*service](c)
+		require.NoError(t, err)
+		s2, err := Resolve[*service](c)
+		require.NoError(t, err)
+		require.Same(t, s1, s2)
+		require.Equal(t, "sql", s1.repo.name)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("override", func(t *testing.T) {
+		c := New()
+		Provide(c, func(*Container) (*repo, error) { return &repo{name: "sql"}, nil })
+		Provide(c, func(c *Container) (*service, error) {
+			return &service{repo: MustResolve[*repo](c)}, nil
+		})
+		Override(c, func(*Container) (*repo, error) { return &repo{name: "fake"}, nil })
+
+		s := MustResolve[*service](c)
+		require.Equal(t, "fake", s.repo.name)
+	})
+
+	t.Run("supply", func(t *testing.T) {
+		c := New()
+		Supply(c, 42)
+		require.Equal(t, 42, MustResolve[int](c))
+	})
+
+	t.Run("nil interface", func(t *testing.T) {
+		c := New()
+		Provide(c, func(*Container) (store, error) { return nil, nil })
+
+		s, err := Resolve[store](c)
+		require.NoError(t, err)
+		require.Nil(t, s)
+		require.Nil(t, MustResolve[store](c))
+	})
+
+	t.Run("missing provider", func(t *testing.T) {
+		c := New()
+		Provide(c, func(c *Container) (*service, error) {
+			return &service{repo: MustResolve[*repo](c)}, nil
+		})
+		_, err := Resolve[*service](c)
+		require.ErrorContains(t, err, "no provider for *di.repo")
+	})
+
+	t.Run("constructor error", func(t *testing.T) {
+		c := New()
+		boom := errors.New("boom")
+		Provide(c, func(*Container) (*repo, error) { return nil, boom })
+		_, err := Resolve[*repo](c)
+		require.ErrorIs(t, err, boom)
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		c := New()
+		Provide(c, func(c *Container) (*a, error) { MustResolve[*b](c); return &a{}, nil })
+		Provide(c, func(c *Container) (*b, error) { MustResolve[*a](c); return &b{}, nil })
+		_, err := Resolve[*a](c)
+		require.ErrorContains(t, err, "dependency cycle *di.a -> *di.b -> *di.a")
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		c := New()
+		Supply(c, 1)
+		require.Panics(t, func() { Supply(c, 2) })
+	})
+}
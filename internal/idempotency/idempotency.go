@@ -0,0 +1,118 @@
+// Package idempotency stores the responses of requests sent with an
+// idempotency key, so that a retried request gets the first response
+// replayed instead of being executed again. The HTTP middleware and the gRPC
+// interceptor built on it live next to the other transport middlewares.
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MaxKeyLength is the longest accepted idempotency key.
+const MaxKeyLength = 255
+
+var (
+	// ErrInFlight is returned by Store.Begin while another request with the
+	// same key is being handled.
+	ErrInFlight = errors.New("idempotency: request with the same key is in progress")
+	// ErrMismatch is returned by Store.Begin when the key was used with a
+	// different request.
+	ErrMismatch = errors.New("idempotency: key was used with a different request")
+)
+
+// Response is a stored response. Status is the HTTP status or the gRPC code.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Store keeps the responses by key. Keys are scoped by the caller, e.g. to the
+// method and path of the request.
+type Store interface {
+	// Begin reserves key for a request identified by fingerprint. It returns
+	// nil when the request should be handled, followed by Complete or
+	// Release, and the stored response when it has already been handled.
+	Begin(ctx context.Context, key, fingerprint string) (*Response, error)
+	// Complete stores the response of the request that reserved key.
+	Complete(ctx context.Context, key string, resp *Response) error
+	// Release drops the reservation of key, so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+type entry struct {
+	fingerprint string
+	resp        *Response
+	expires     time.Time
+}
+
+// MemoryStore is a Store local to the process. Replicas do not share it, so
+// retries routed to another replica are executed again.
+type MemoryStore struct {
+	ttl       time.Duration
+	now       func() time.Time
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns a store keeping responses and reservations for ttl.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{ttl: ttl, now: time.Now, entries: map[string]*entry{}}
+}
+
+func (s *MemoryStore) Begin(_ context.Context, key, fingerprint string) (*Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		switch {
+		case e.fingerprint != fingerprint:
+			return nil, ErrMismatch
+		case e.resp == nil:
+			return nil, ErrInFlight
+		default:
+			return e.resp, nil
+		}
+	}
+	s.entries[key] = &entry{fingerprint: fingerprint, expires: now.Add(s.ttl)}
+	return nil, nil
+}
+
+func (s *MemoryStore) Complete(_ context.Context, key string, resp *Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		e.resp = resp
+		e.expires = s.now().Add(s.ttl)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok && e.resp == nil {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// sweep drops expired entries, at most once a minute.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for k, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := NewMemoryStore(time.Hour)
+	s.now = func() time.Time { return now }
+
+	resp, err := s.Begin(ctx, "k", "a")
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	_, err = s.Begin(ctx, "k", "a")
+	require.ErrorIs(t, err, ErrInFlight)
+	_, err = s.Begin(ctx, "k", "b")
+	require.ErrorIs(t, err, ErrMismatch)
+
+	require.NoError(t, s.Complete(ctx, "k", &Response{Status: 201, Body: []byte("done")}))
+	resp, err = s.Begin(ctx, "k", "a")
+	require.NoError(t, err)
+	require.Equal(t, []byte("done"), resp.Body)
+
+	// a completed response survives Release and expires after the ttl
+	require.NoError(t, s.Release(ctx, "k"))
+	resp, err = s.Begin(ctx, "k", "a")
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+
+	now = now.Add(2 * time.Hour)
+	resp, err = s.Begin(ctx, "k", "b")
+	require.NoError(t, err)
+	require.Nil(t, resp)
+	require.Len(t, s.entries, 1, "expired entries are swept")
+
+	require.NoError(t, s.Release(ctx, "k"))
+	resp, err = s.Begin(ctx, "k", "a")
+	require.NoError(t, err)
+	require.Nil(t, resp)
+}
@@ -0,0 +1,271 @@
+// Package jobs runs background work as lifecycle components: a Scheduler
+// runs jobs on cron schedules and a Pool runs jobs queued by the
+// application on a fixed number of workers. Both retry failed jobs with
+// backoff, recover panics, log and count every run, and on shutdown stop
+// starting jobs and wait for the running ones.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ravilushqa/boilerplate/internal/recovery"
+)
+
+var (
+	jobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "jobs_runs_total",
+		Help: "Number of job runs by job and result (success, failure or skipped).",
+	}, []string{"job", "result"})
+	jobRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "jobs_retries_total",
+		Help: "Number of retried job attempts by job.",
+	}, []string{"job"})
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "jobs_run_duration_seconds",
+		Help:    "Duration of job runs, retries included, by job.",
+		Buckets: []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300, 900},
+	}, []string{"job"})
+	runningJobs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "jobs_running",
+		Help: "Number of running jobs by job.",
+	}, []string{"job"})
+	queuedJobs = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "jobs_queued",
+		Help: "Number of jobs waiting for a Pool worker.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(jobRuns, jobRetries, jobDuration, runningJobs, queuedJobs)
+}
+
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+	resultSkipped = "skipped"
+)
+
+// ErrClosed is returned by Submit once the Pool is shutting down.
+var ErrClosed = errors.New("jobs: shutting down")
+
+// maxBackoff caps the delay between attempts.
+const maxBackoff = 5 * time.Minute
+
+// Func is the work of a job. Its context is canceled on shutdown and when
+// the job's Timeout expires.
+type Func func(ctx context.Context) error
+
+// permanentError is an error not worth retrying.
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, the run fails at once.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// Locker takes locks shared by the replicas, e.g. database advisory locks,
+// so that a Singleton job runs in one replica at a time.
+type Locker interface {
+	// TryLock takes the lock named key without waiting. It returns false
+	// when another holder has it, otherwise unlock releases it.
+	TryLock(ctx context.Context, key string) (unlock func(), ok bool, err error)
+}
+
+// Option configures a Scheduler or a Pool.
+type Option interface {
+	apply(*executor)
+}
+
+type optionFunc func(*executor)
+
+func (f optionFunc) apply(e *executor) { f(e) }
+
+// WithRecovery sets the handler of panicking jobs.
+func WithRecovery(h *recovery.Handler) Option {
+	return optionFunc(func(e *executor) {
+		e.recovery = h
+	})
+}
+
+// WithLocker makes Singleton jobs of a Scheduler take a lock of locker, so
+// they run in one replica at a time. Without it they only do not overlap
+// within the process.
+func WithLocker(locker Locker) Option {
+	return optionFunc(func(e *executor) {
+		e.locker = locker
+	})
+}
+
+// JobOption configures a job.
+type JobOption interface {
+	apply(*job)
+}
+
+type jobOptionFunc func(*job)
+
+func (f jobOptionFunc) apply(j *job) { f(j) }
+
+// Retry makes up to attempts attempts of a failing job, waiting backoff
+// after the first failure and doubling the wait after each following one.
+// Errors marked Permanent and panics are not retried.
+func Retry(attempts int, backoff time.Duration) JobOption {
+	return jobOptionFunc(func(j *job) {
+		j.attempts, j.backoff = max(attempts, 1), backoff
+	})
+}
+
+// Timeout cancels the context of every attempt of the job after d.
+func Timeout(d time.Duration) JobOption {
+	return jobOptionFunc(func(j *job) {
+		j.timeout = d
+	})
+}
+
+// Jitter delays every scheduled run by a random duration up to d, so
+// replicas do not all start a job at the same instant. Pool jobs ignore it.
+func Jitter(d time.Duration) JobOption {
+	return jobOptionFunc(func(j *job) {
+		j.jitter = d
+	})
+}
+
+// Singleton skips a scheduled run while the previous one still runs, or
+// while another replica runs the job when the Scheduler has a Locker. Pool
+// jobs ignore it.
+func Singleton() JobOption {
+	return jobOptionFunc(func(j *job) {
+		j.singleton = true
+	})
+}
+
+type job struct {
+	name      string
+	f         Func
+	attempts  int
+	backoff   time.Duration
+	timeout   time.Duration
+	jitter    time.Duration
+	singleton bool
+}
+
+func newJob(name string, f Func, opts []JobOption) *job {
+	j := &job{name: name, f: f, attempts: 1}
+	for _, o := range opts {
+		o.apply(j)
+	}
+	return j
+}
+
+// delay returns the wait before the attempt following attempt, with jitter
+// so that retries of many jobs failing together spread out.
+func (j *job) delay(attempt int) time.Duration {
+	d := j.backoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d = min(d, maxBackoff); d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// executor runs jobs for the Scheduler and the Pool.
+type executor struct {
+	l        *slog.Logger
+	recovery *recovery.Handler
+	locker   Locker
+}
+
+func newExecutor(l *slog.Logger, opts []Option) *executor {
+	e := &executor{l: l}
+	for _, o := range opts {
+		o.apply(e)
+	}
+	if e.recovery == nil {
+		e.recovery = recovery.New(l, nil)
+	}
+	return e
+}
+
+// run runs j until an attempt succeeds, the attempts are exhausted or ctx
+// is done.
+func (e *executor) run(ctx context.Context, j *job) {
+	runningJobs.WithLabelValues(j.name).Inc()
+	defer runningJobs.WithLabelValues(j.name).Dec()
+	start := time.Now()
+
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		err = e.attempt(ctx, j)
+		if err == nil || attempt >= j.attempts || errors.As(err, &permanentError{}) || ctx.Err() != nil {
+			break
+		}
+		d := j.delay(attempt)
+		e.l.Warn("[JOBS] attempt failed, retrying",
+			slog.String("job", j.name), slog.Int("attempt", attempt), slog.Duration("backoff", d), slog.Any("error", err))
+		jobRetries.WithLabelValues(j.name).Inc()
+		if !sleep(ctx, d) {
+			break
+		}
+	}
+
+	duration := time.Since(start)
+	jobDuration.WithLabelValues(j.name).Observe(duration.Seconds())
+	attrs := []any{slog.String("job", j.name), slog.Int("attempts", attempt), slog.Duration("duration", duration)}
+	if err != nil {
+		jobRuns.WithLabelValues(j.name, resultFailure).Inc()
+		e.l.Error("[JOBS] job failed", append(attrs, slog.Any("error", err))...)
+		return
+	}
+	jobRuns.WithLabelValues(j.name, resultSuccess).Inc()
+	e.l.Info("[JOBS] job completed", attrs...)
+}
+
+// attempt calls the job once, a panic becomes a Permanent error carrying
+// the correlation ID it was logged under.
+func (e *executor) attempt(ctx context.Context, j *job) (err error) {
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+		defer cancel()
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			id := e.recovery.Handle(ctx, p, "jobs", map[string]string{"job": j.name})
+			err = Permanent(fmt.Errorf("panic, correlation id %s", id))
+		}
+	}()
+	return j.f(ctx)
+}
+
+// sleep waits d, it returns false when ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// skip counts a run that did not start.
+func (e *executor) skip(j *job, reason string) {
+	jobRuns.WithLabelValues(j.name, resultSkipped).Inc()
+	e.l.Info("[JOBS] job skipped", slog.String("job", j.name), slog.String("reason", reason))
+}
@@ -0,0 +1,191 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler(t *testing.T) {
+	s := NewScheduler(slog.Default())
+	runs := make(chan struct{}, 10)
+	require.NoError(t, s.Add("tick", "@every 10ms", func(context.Context) error {
+		runs <- struct{}{}
+		return nil
+	}, Jitter(time.Millisecond)))
+	require.ErrorContains(t, s.Add("tick", "@every 1s", nil), "duplicate")
+	require.ErrorContains(t, s.Add("bad", "* *", nil), "job bad")
+
+	// a singleton job blocking its first run is skipped until it returns
+	release := make(chan struct{})
+	var singletons atomic.Int32
+	require.NoError(t, s.Add("singleton", "@every 5ms", func(ctx context.Context) error {
+		if singletons.Add(1) == 1 {
+			<-release
+		}
+		return nil
+	}, Singleton()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- s.Run(ctx) }()
+	<-runs
+	<-runs
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int32(1), singletons.Load(), "no overlapping run")
+	close(release)
+	require.Eventually(t, func() bool { return singletons.Load() > 1 }, time.Second, time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-stopped)
+	require.ErrorContains(t, s.Add("late", "@hourly", nil), "already running")
+}
+
+func TestScheduler_noJobs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- NewScheduler(slog.Default()).Run(ctx) }()
+	select {
+	case <-stopped:
+		t.Fatal("scheduler stopped before its context was canceled")
+	case <-time.After(20 * time.Millisecond):
+	}
+	cancel()
+	require.NoError(t, <-stopped)
+}
+
+type locker struct {
+	mu   sync.Mutex
+	held map[string]bool
+}
+
+func (l *locker) TryLock(_ context.Context, key string) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held[key] {
+		return nil, false, nil
+	}
+	l.held[key] = true
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.held, key)
+	}, true, nil
+}
+
+func TestScheduler_locker(t *testing.T) {
+	// the lock of the job is held by another replica
+	lock := &locker{held: map[string]bool{"jobs/report": true}}
+	s := NewScheduler(slog.Default(), WithLocker(lock))
+	var runs atomic.Int32
+	require.NoError(t, s.Add("report", "@every 5ms", func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}, Singleton()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- s.Run(ctx) }()
+	time.Sleep(30 * time.Millisecond)
+	require.Zero(t, runs.Load())
+
+	lock.mu.Lock()
+	delete(lock.held, "jobs/report")
+	lock.mu.Unlock()
+	require.Eventually(t, func() bool { return runs.Load() > 0 }, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-stopped)
+}
+
+func TestPool(t *testing.T) {
+	p := NewPool(slog.Default(), PoolConfig{Workers: 2})
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- p.Run(ctx) }()
+
+	results := make(chan int, 3)
+	var attempts atomic.Int32
+	require.NoError(t, p.Submit(ctx, "flaky", func(context.Context) error {
+		if n := attempts.Add(1); n < 3 {
+			return errors.New("unavailable")
+		}
+		results <- 1
+		return nil
+	}, Retry(3, time.Millisecond)))
+	require.Equal(t, 1, <-results)
+	require.Equal(t, int32(3), attempts.Load())
+
+	var permanent atomic.Int32
+	done := make(chan struct{})
+	require.NoError(t, p.Submit(ctx, "permanent", func(context.Context) error {
+		defer close(done)
+		permanent.Add(1)
+		return Permanent(errors.New("invalid input"))
+	}, Retry(3, time.Millisecond)))
+	<-done
+
+	panicked := make(chan struct{})
+	require.NoError(t, p.Submit(ctx, "panic", func(context.Context) error {
+		close(panicked)
+		panic("boom")
+	}, Retry(3, time.Millisecond)))
+	<-panicked
+
+	// queued jobs are run on shutdown
+	release := make(chan struct{})
+	for range 2 {
+		require.NoError(t, p.Submit(ctx, "blocked", func(context.Context) error {
+			<-release
+			return nil
+		}))
+	}
+	var drained atomic.Bool
+	require.NoError(t, p.Submit(ctx, "queued", func(context.Context) error {
+		drained.Store(true)
+		return nil
+	}))
+	cancel()
+	require.Eventually(t, func() bool {
+		return errors.Is(p.Submit(context.Background(), "late", nil), ErrClosed)
+	}, time.Second, time.Millisecond)
+	close(release)
+	require.NoError(t, <-stopped)
+	require.True(t, drained.Load())
+	require.Equal(t, int32(1), permanent.Load(), "permanent errors are not retried")
+}
+
+func TestPool_drainTimeout(t *testing.T) {
+	p := NewPool(slog.Default(), PoolConfig{Workers: 1, QueueSize: 1, DrainTimeout: 10 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- p.Run(ctx) }()
+
+	started := make(chan struct{})
+	canceled := make(chan error, 1)
+	require.NoError(t, p.Submit(ctx, "slow", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		return ctx.Err()
+	}))
+	<-started
+	var ran atomic.Bool
+	require.NoError(t, p.Submit(ctx, "queued", func(context.Context) error {
+		ran.Store(true)
+		return nil
+	}))
+	submitCtx, submitCancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer submitCancel()
+	require.ErrorIs(t, p.Submit(submitCtx, "full", nil), context.DeadlineExceeded)
+
+	cancel()
+	require.NoError(t, <-stopped)
+	require.ErrorIs(t, <-canceled, context.Canceled)
+	require.False(t, ran.Load(), "dropped after the drain timeout")
+}
@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// PoolConfig configures a Pool.
+type PoolConfig struct {
+	// Workers is the number of jobs run at once. 10 by default.
+	Workers int
+	// QueueSize is the number of jobs waiting for a worker, Submit blocks
+	// while the queue is full. 100 by default.
+	QueueSize int
+	// DrainTimeout is how long the queued and running jobs have to finish
+	// on shutdown. Then the contexts of the running jobs are canceled and
+	// the queued ones are dropped. 10s by default.
+	DrainTimeout time.Duration
+}
+
+// Pool runs submitted jobs on a fixed number of workers. It runs as a
+// lifecycle component: jobs submitted before it starts wait in the queue,
+// once stopped no job is accepted and the queue is drained. The queue is
+// kept in memory, jobs that must survive a restart are stored by the
+// application and submitted again.
+type Pool struct {
+	e     *executor
+	cfg   PoolConfig
+	queue chan *job
+	// closing unblocks Submit on shutdown, mu orders it before the queue
+	// is closed
+	closing chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+}
+
+// NewPool returns a Pool.
+func NewPool(l *slog.Logger, cfg PoolConfig, opts ...Option) *Pool {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 10
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 100
+	}
+	if cfg.DrainTimeout <= 0 {
+		cfg.DrainTimeout = 10 * time.Second
+	}
+	return &Pool{
+		e:       newExecutor(l, opts),
+		cfg:     cfg,
+		queue:   make(chan *job, cfg.QueueSize),
+		closing: make(chan struct{}),
+	}
+}
+
+// Submit queues f to run under name, which labels its logs and metrics.
+// It waits for room in the queue until ctx is done and returns ErrClosed
+// once the Pool is shutting down. f runs detached from ctx.
+func (p *Pool) Submit(ctx context.Context, name string, f Func, opts ...JobOption) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrClosed
+	}
+	select {
+	case p.queue <- newJob(name, f, opts):
+		queuedJobs.Inc()
+		return nil
+	case <-p.closing:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run runs the queued jobs until ctx is done, then drains the queue.
+func (p *Pool) Run(ctx context.Context) error {
+	// jobs outlive ctx until the drain timeout
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	var wg sync.WaitGroup
+	for range p.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range p.queue {
+				queuedJobs.Dec()
+				if jobCtx.Err() != nil {
+					p.e.skip(j, "dropped on shutdown")
+					continue
+				}
+				p.e.run(jobCtx, j)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	close(p.closing)
+	p.mu.Lock()
+	p.closed = true
+	close(p.queue)
+	p.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+	t := time.NewTimer(p.cfg.DrainTimeout)
+	defer t.Stop()
+	select {
+	case <-drained:
+	case <-t.C:
+		p.e.l.Warn("[JOBS] drain timed out, canceling the running jobs")
+		cancel()
+		<-drained
+	}
+	return nil
+}
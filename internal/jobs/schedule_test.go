@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// a Wednesday
+	from := time.Date(2024, time.January, 31, 10, 17, 30, 0, time.UTC)
+	for spec, want := range map[string]time.Time{
+		"* * * * *":        time.Date(2024, time.January, 31, 10, 18, 0, 0, time.UTC),
+		"*/15 * * * *":     time.Date(2024, time.January, 31, 10, 30, 0, 0, time.UTC),
+		"0 9-17/4 * * *":   time.Date(2024, time.January, 31, 13, 0, 0, 0, time.UTC),
+		"30 2 * * *":       time.Date(2024, time.February, 1, 2, 30, 0, 0, time.UTC),
+		"0 0 29 feb *":     time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		"0 0 * * sun":      time.Date(2024, time.February, 4, 0, 0, 0, 0, time.UTC),
+		"0 0 * * 7":        time.Date(2024, time.February, 4, 0, 0, 0, 0, time.UTC),
+		"0 0 1,15 * mon":   time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+		"5,10 10 31 1 *":   time.Date(2025, time.January, 31, 10, 5, 0, 0, time.UTC),
+		"@hourly":          time.Date(2024, time.January, 31, 11, 0, 0, 0, time.UTC),
+		"@monthly":         time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+		"@every 90s":       from.Add(90 * time.Second),
+		"0 0 30 feb *":     {},
+		" 0  12  *  *  * ": time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC),
+	} {
+		s, err := ParseSchedule(spec)
+		require.NoError(t, err, spec)
+		require.Equal(t, want, s.Next(from), spec)
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *", "@every", "@every -1s", "@often"} {
+		_, err := ParseSchedule(spec)
+		require.Error(t, err, spec)
+	}
+}
+
+func TestParseSchedule_location(t *testing.T) {
+	loc := time.FixedZone("IST", 5*3600+1800)
+	s, err := ParseSchedule("0 * * * *")
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, time.January, 1, 11, 0, 0, 0, loc), s.Next(time.Date(2024, time.January, 1, 10, 10, 0, 0, loc)))
+}
@@ -0,0 +1,127 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Scheduler runs jobs on schedules, evaluated in UTC. It runs as a
+// lifecycle component: jobs are added before it starts, once stopped no
+// run starts and the running ones are canceled and waited for.
+type Scheduler struct {
+	e *executor
+
+	mu      sync.Mutex
+	started bool
+	jobs    []*scheduled
+}
+
+type scheduled struct {
+	*job
+	schedule Schedule
+	running  atomic.Bool
+}
+
+// NewScheduler returns a Scheduler without jobs.
+func NewScheduler(l *slog.Logger, opts ...Option) *Scheduler {
+	return &Scheduler{e: newExecutor(l, opts)}
+}
+
+// Add schedules f under a unique name. spec is parsed by ParseSchedule,
+// e.g. "*/5 * * * *" or "@every 30s".
+func (s *Scheduler) Add(name, spec string, f Func, opts ...JobOption) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return errors.New("jobs: scheduler already running")
+	}
+	for _, j := range s.jobs {
+		if j.name == name {
+			return fmt.Errorf("jobs: duplicate job %s", name)
+		}
+	}
+	s.jobs = append(s.jobs, &scheduled{job: newJob(name, f, opts), schedule: schedule})
+	return nil
+}
+
+// Run runs the jobs on their schedules until ctx is done, then waits for
+// the running jobs, whose contexts are canceled.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	s.started = true
+	jobs := s.jobs
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, j, &wg)
+		}()
+	}
+	// a scheduler without jobs runs too, until stopped
+	<-ctx.Done()
+	wg.Wait()
+	return nil
+}
+
+// loop starts the runs of j until ctx is done. Runs are added to wg.
+func (s *Scheduler) loop(ctx context.Context, j *scheduled, wg *sync.WaitGroup) {
+	for {
+		now := time.Now().UTC()
+		next := j.schedule.Next(now)
+		if next.IsZero() {
+			s.e.l.Warn("[JOBS] schedule never matches", slog.String("job", j.name))
+			return
+		}
+		d := next.Sub(now)
+		if j.jitter > 0 {
+			d += rand.N(j.jitter)
+		}
+		if !sleep(ctx, d) {
+			return
+		}
+
+		if j.singleton && !j.running.CompareAndSwap(false, true) {
+			s.e.skip(j.job, "previous run still running")
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if j.singleton {
+				defer j.running.Store(false)
+			}
+			s.start(ctx, j)
+		}()
+	}
+}
+
+// start runs j, holding the lock of the Locker for Singleton jobs.
+func (s *Scheduler) start(ctx context.Context, j *scheduled) {
+	if j.singleton && s.e.locker != nil {
+		unlock, ok, err := s.e.locker.TryLock(ctx, "jobs/"+j.name)
+		if err != nil {
+			jobRuns.WithLabelValues(j.name, resultFailure).Inc()
+			s.e.l.Error("[JOBS] failed to take the job lock", slog.String("job", j.name), slog.Any("error", err))
+			return
+		}
+		if !ok {
+			s.e.skip(j.job, "running in another replica")
+			return
+		}
+		defer unlock()
+	}
+	s.e.run(ctx, j.job)
+}
@@ -0,0 +1,343 @@
+// Package lifecycle starts application components in dependency order and
+// stops them in reverse order on shutdown.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrExited is the error of a Runner whose Run returned nil before it was
+// stopped.
+var ErrExited = errors.New("exited before it was stopped")
+
+// Component is started once and stopped once. Start must not block beyond
+// the initialization of the component.
+type Component interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// Runner blocks in Run until ctx is canceled, like the transport servers.
+type Runner interface {
+	Run(ctx context.Context) error
+}
+
+// State is the lifecycle state of a component.
+type State int
+
+const (
+	StatePending State = iota
+	StateStarting
+	StateRunning
+	StateStopping
+	StateStopped
+	StateFailed
+)
+
+func (s State) String() string {
+	switch s {
+	case StatePending:
+		return "pending"
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateStopping:
+		return "stopping"
+	case StateStopped:
+		return "stopped"
+	case StateFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// Option configures a component added to the Manager.
+type Option func(*unit)
+
+// DependsOn declares components that must be running before this one starts.
+// They are stopped only after this one has stopped.
+func DependsOn(names ...string) Option {
+	return func(u *unit) {
+		u.deps = append(u.deps, names...)
+	}
+}
+
+// StopTimeout overrides the manager default stop timeout for the component.
+func StopTimeout(d time.Duration) Option {
+	return func(u *unit) {
+		u.stopTimeout = d
+	}
+}
+
+type unit struct {
+	name        string
+	deps        []string
+	stopTimeout time.Duration
+	state       State
+
+	start func(ctx context.Context) error
+	stop  func(ctx context.Context) error
+}
+
+// Manager owns the components of the application.
+type Manager struct {
+	l           *slog.Logger
+	stopTimeout time.Duration
+
+	mu    sync.RWMutex
+	units []*unit
+	byKey map[string]*unit
+
+	// failed is closed once a runner exits on its own, exited holds the
+	// errors of all the runners that did.
+	failed     chan struct{}
+	failedOnce sync.Once
+	exited     []error
+}
+
+// New returns a manager stopping each component within stopTimeout unless
+// overridden with StopTimeout.
+func New(l *slog.Logger, stopTimeout time.Duration) *Manager {
+	return &Manager{
+		l:           l,
+		stopTimeout: stopTimeout,
+		byKey:       map[string]*unit{},
+		failed:      make(chan struct{}),
+	}
+}
+
+// Add registers a component under a unique name.
+func (m *Manager) Add(name string, c Component, opts ...Option) {
+	m.add(name, c.Start, c.Stop, opts)
+}
+
+// AddRunner registers a Runner. It is started in its own goroutine and
+// stopped by canceling its context and waiting for Run to return. A Runner
+// returning before it is stopped, with or without an error, shuts the whole
+// manager down.
+func (m *Manager) AddRunner(name string, r Runner, opts ...Option) {
+	var (
+		cancel context.CancelFunc
+		done   chan struct{}
+		runErr error
+	)
+	start := func(ctx context.Context) error {
+		// the start context only bounds startup, runners live until stopped
+		runCtx, c := context.WithCancel(context.WithoutCancel(ctx))
+		cancel, done = c, make(chan struct{})
+		go func() {
+			defer close(done)
+			err := r.Run(runCtx)
+			if runCtx.Err() != nil {
+				runErr = err
+				return
+			}
+			if err == nil {
+				err = ErrExited
+			}
+			m.setState(name, StateFailed)
+			m.fail(fmt.Errorf("%s: %w", name, err))
+		}()
+		return nil
+	}
+	stop := func(ctx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return runErr
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	m.add(name, start, stop, opts)
+}
+
+func (m *Manager) add(name string, start, stop func(context.Context) error, opts []Option) {
+	u := &unit{name: name, stopTimeout: m.stopTimeout, start: start, stop: stop}
+	for _, opt := range opts {
+		opt(u)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.byKey[name]; ok {
+		panic("lifecycle: duplicate component " + name)
+	}
+	m.units = append(m.units, u)
+	m.byKey[name] = u
+}
+
+// Run starts all components in dependency order, blocks until ctx is
+// canceled or a runner fails, then stops the started components in reverse
+// order. It returns the first start or run error joined with stop errors.
+func (m *Manager) Run(ctx context.Context) error {
+	order, err := m.order()
+	if err != nil {
+		return err
+	}
+
+	var runErr error
+	started := make([]*unit, 0, len(order))
+	for _, u := range order {
+		if m.hasFailed() {
+			// a runner exited during startup, do not start anything after it
+			break
+		}
+		m.setState(u.name, StateStarting)
+		m.l.Info("[LIFECYCLE] starting", slog.String("component", u.name))
+		if err = u.start(ctx); err != nil {
+			m.setState(u.name, StateFailed)
+			runErr = fmt.Errorf("start %s: %w", u.name, err)
+			break
+		}
+		started = append(started, u)
+		m.setState(u.name, StateRunning)
+	}
+
+	if runErr == nil {
+		select {
+		case <-ctx.Done():
+		case <-m.failed:
+		}
+	}
+
+	// readiness must fail before anything is torn down
+	for _, u := range started {
+		m.setState(u.name, StateStopping)
+	}
+
+	var stopErrs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		stopErrs = append(stopErrs, m.stopUnit(started[i]))
+	}
+
+	m.mu.RLock()
+	errs := append([]error{runErr}, m.exited...)
+	m.mu.RUnlock()
+	return errors.Join(append(errs, stopErrs...)...)
+}
+
+func (m *Manager) hasFailed() bool {
+	select {
+	case <-m.failed:
+		return true
+	default:
+		return false
+	}
+}
+
+// fail records the error of a runner that exited on its own and has Run
+// stop the components.
+func (m *Manager) fail(err error) {
+	m.l.Error("[LIFECYCLE] component exited", slog.Any("error", err))
+	m.mu.Lock()
+	m.exited = append(m.exited, err)
+	m.mu.Unlock()
+	m.failedOnce.Do(func() { close(m.failed) })
+}
+
+func (m *Manager) stopUnit(u *unit) error {
+	m.l.Info("[LIFECYCLE] stopping", slog.String("component", u.name))
+	ctx, cancel := context.WithTimeout(context.Background(), u.stopTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := u.stop(ctx); err != nil {
+		m.setState(u.name, StateFailed)
+		m.l.Error("[LIFECYCLE] stop failed", slog.String("component", u.name), slog.Any("error", err))
+		return fmt.Errorf("stop %s: %w", u.name, err)
+	}
+	m.setState(u.name, StateStopped)
+	m.l.Info("[LIFECYCLE] stopped", slog.String("component", u.name), slog.Duration("duration", time.Since(start)))
+	return nil
+}
+
+// order returns the components topologically sorted by their dependencies,
+// keeping registration order between independent components.
+func (m *Manager) order() ([]*unit, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+	marks := make(map[string]int, len(m.units))
+	order := make([]*unit, 0, len(m.units))
+
+	var visit func(u *unit, path []string) error
+	visit = func(u *unit, path []string) error {
+		switch marks[u.name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("lifecycle: dependency cycle %v", append(path, u.name))
+		}
+		marks[u.name] = visiting
+		for _, dep := range u.deps {
+			d, ok := m.byKey[dep]
+			if !ok {
+				return fmt.Errorf("lifecycle: %s depends on unknown component %s", u.name, dep)
+			}
+			if err := visit(d, append(path, u.name)); err != nil {
+				return err
+			}
+		}
+		marks[u.name] = visited
+		order = append(order, u)
+		return nil
+	}
+	for _, u := range m.units {
+		if err := visit(u, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// setState moves the named component to s. A failed component stays failed,
+// so State and Check keep reporting why the manager is stopping.
+func (m *Manager) setState(name string, s State) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if u := m.byKey[name]; u.state != StateFailed {
+		u.state = s
+	}
+}
+
+// State returns the state of the named component.
+func (m *Manager) State(name string) State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if u, ok := m.byKey[name]; ok {
+		return u.state
+	}
+	return StatePending
+}
+
+// Check is a readiness check reporting an error unless every component is
+// running. A failed component is reported before any other.
+func (m *Manager) Check(context.Context) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var notRunning *unit
+	for _, u := range m.units {
+		if u.state == StateFailed {
+			return fmt.Errorf("%s is %s", u.name, u.state)
+		}
+		if u.state != StateRunning && notRunning == nil {
+			notRunning = u
+		}
+	}
+	if notRunning != nil {
+		return fmt.Errorf("%s is %s", notRunning.name, notRunning.state)
+	}
+	return nil
+}
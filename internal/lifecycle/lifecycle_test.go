@@ -0,0 +1,188 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(e string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+type component struct {
+	name      string
+	rec       *recorder
+	startErr  error
+	startWait time.Duration
+	stopWait  time.Duration
+}
+
+func (c *component) Start(context.Context) error {
+	time.Sleep(c.startWait)
+	c.rec.add("start " + c.name)
+	return c.startErr
+}
+
+func (c *component) Stop(ctx context.Context) error {
+	select {
+	case <-time.After(c.stopWait):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	c.rec.add("stop " + c.name)
+	return nil
+}
+
+type runner struct {
+	name string
+	rec  *recorder
+	err  error
+}
+
+func (r *runner) Run(ctx context.Context) error {
+	r.rec.add("run " + r.name)
+	if r.err != nil {
+		return r.err
+	}
+	<-ctx.Done()
+	r.rec.add("done " + r.name)
+	return nil
+}
+
+type runFunc func(ctx context.Context) error
+
+func (f runFunc) Run(ctx context.Context) error { return f(ctx) }
+
+func TestManager_Run(t *testing.T) {
+	rec := &recorder{}
+	m := New(slog.Default(), time.Second)
+	m.AddRunner("http", &runner{name: "http", rec: rec}, DependsOn("db", "cache"))
+	m.Add("cache", &component{name: "cache", rec: rec}, DependsOn("db"))
+	m.Add("db", &component{name: "db", rec: rec})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() { errCh <- m.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return m.Check(ctx) == nil }, time.Second, 10*time.Millisecond)
+	require.Equal(t, StateRunning, m.State("http"))
+
+	cancel()
+	require.NoError(t, <-errCh)
+	require.Equal(t, []string{"start db", "start cache", "run http", "done http", "stop cache", "stop db"}, rec.get())
+	require.Equal(t, StateStopped, m.State("db"))
+	require.Error(t, m.Check(ctx))
+}
+
+func TestManager_Run_startFailure(t *testing.T) {
+	rec := &recorder{}
+	m := New(slog.Default(), time.Second)
+	m.Add("db", &component{name: "db", rec: rec})
+	m.Add("cache", &component{name: "cache", rec: rec, startErr: errors.New("boom")}, DependsOn("db"))
+	m.Add("worker", &component{name: "worker", rec: rec}, DependsOn("cache"))
+
+	err := m.Run(context.Background())
+	require.ErrorContains(t, err, "start cache: boom")
+	require.Equal(t, []string{"start db", "start cache", "stop db"}, rec.get())
+	require.Equal(t, StateFailed, m.State("cache"))
+	require.Equal(t, StatePending, m.State("worker"))
+}
+
+func TestManager_Run_runnerFailure(t *testing.T) {
+	rec := &recorder{}
+	m := New(slog.Default(), time.Second)
+	m.Add("db", &component{name: "db", rec: rec})
+	m.AddRunner("grpc", &runner{name: "grpc", rec: rec, err: errors.New("address in use")}, DependsOn("db"))
+
+	err := m.Run(context.Background())
+	require.ErrorContains(t, err, "grpc: address in use")
+	require.Contains(t, rec.get(), "stop db")
+}
+
+func TestManager_Run_runnerExit(t *testing.T) {
+	rec := &recorder{}
+	m := New(slog.Default(), time.Second)
+	m.Add("db", &component{name: "db", rec: rec})
+	m.AddRunner("scheduler", runFunc(func(context.Context) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}), DependsOn("db"))
+	// the worker fails while the api depending on it is still stopping
+	m.AddRunner("worker", runFunc(func(context.Context) error {
+		time.Sleep(50 * time.Millisecond)
+		return errors.New("queue closed")
+	}), DependsOn("db"))
+	m.Add("api", &component{name: "api", rec: rec, stopWait: 200 * time.Millisecond}, DependsOn("worker"))
+
+	err := m.Run(context.Background())
+	require.ErrorIs(t, err, ErrExited)
+	require.ErrorContains(t, err, "scheduler: exited before it was stopped")
+	require.ErrorContains(t, err, "worker: queue closed")
+	require.Equal(t, StateStopped, m.State("db"))
+	require.Equal(t, StateFailed, m.State("scheduler"))
+	require.ErrorContains(t, m.Check(context.Background()), "scheduler is failed")
+}
+
+func TestManager_Run_runnerExitOnStartup(t *testing.T) {
+	rec := &recorder{}
+	m := New(slog.Default(), time.Second)
+	m.Add("db", &component{name: "db", rec: rec})
+	m.AddRunner("grpc", &runner{name: "grpc", rec: rec, err: errors.New("address in use")}, DependsOn("db"))
+	// the grpc server has failed by the time the cache is started
+	m.Add("cache", &component{name: "cache", rec: rec, startWait: 20 * time.Millisecond}, DependsOn("grpc"))
+	m.Add("worker", &component{name: "worker", rec: rec}, DependsOn("cache"))
+
+	err := m.Run(context.Background())
+	require.ErrorContains(t, err, "grpc: address in use")
+	require.NotContains(t, rec.get(), "start worker")
+	require.Equal(t, StatePending, m.State("worker"))
+	require.Equal(t, StateFailed, m.State("grpc"))
+	require.ErrorContains(t, m.Check(context.Background()), "grpc is failed")
+}
+
+func TestManager_Run_stopTimeout(t *testing.T) {
+	rec := &recorder{}
+	m := New(slog.Default(), time.Second)
+	m.Add("slow", &component{name: "slow", rec: rec, stopWait: time.Minute}, StopTimeout(10*time.Millisecond))
+	m.Add("fast", &component{name: "fast", rec: rec})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := m.Run(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Contains(t, rec.get(), "stop fast")
+	require.Equal(t, StateFailed, m.State("slow"))
+}
+
+func TestManager_order(t *testing.T) {
+	t.Run("cycle", func(t *testing.T) {
+		m := New(slog.Default(), time.Second)
+		m.Add("a", &component{name: "a", rec: &recorder{}}, DependsOn("b"))
+		m.Add("b", &component{name: "b", rec: &recorder{}}, DependsOn("a"))
+		require.ErrorContains(t, m.Run(context.Background()), "dependency cycle")
+	})
+	t.Run("unknown", func(t *testing.T) {
+		m := New(slog.Default(), time.Second)
+		m.Add("a", &component{name: "a", rec: &recorder{}}, DependsOn("b"))
+		require.ErrorContains(t, m.Run(context.Background()), "unknown component b")
+	})
+}
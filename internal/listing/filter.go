@@ -0,0 +1,454 @@
+package listing
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxFilterLength bounds the work a client can ask for.
+const maxFilterLength = 2048
+
+// Expr is a parsed filter: And, Or, Not or Compare.
+type Expr interface {
+	// String returns the expression in canonical form.
+	String() string
+	isExpr()
+}
+
+// And matches when all its expressions match.
+type And []Expr
+
+// Or matches when any of its expressions matches.
+type Or []Expr
+
+// Not matches when its expression does not.
+type Not struct{ Expr Expr }
+
+// Compare is a restriction of a field, e.g. name = "World".
+type Compare struct {
+	Field string
+	// Op is one of = != < <= > >= and : (has). On strings, = accepts a
+	// leading or trailing * wildcard and : matches a substring.
+	Op string
+	// Value is typed after the field: string, int64, float64, bool or
+	// time.Time.
+	Value any
+}
+
+func (And) isExpr()     {}
+func (Or) isExpr()      {}
+func (Not) isExpr()     {}
+func (Compare) isExpr() {}
+
+func (e And) String() string { return join(e, " AND ") }
+func (e Or) String() string  { return join(e, " OR ") }
+func (e Not) String() string { return "NOT " + e.Expr.String() }
+func (e Compare) String() string {
+	return e.Field + " " + e.Op + " " + strconv.Quote(formatValue(e.Value))
+}
+
+func join[E ~[]Expr](exprs E, sep string) string {
+	parts := make([]string, len(exprs))
+	for i, e := range exprs {
+		parts[i] = "(" + e.String() + ")"
+	}
+	return strings.Join(parts, sep)
+}
+
+// ParseFilter parses an AIP-160 filter on fields. The supported subset is
+// restrictions on fields (name = "World", create_time > "2026-01-02T00:00:00Z"),
+// AND, OR, NOT or -, parentheses, and juxtaposition as AND. As in AIP-160,
+// OR binds tighter than AND: a AND b OR c is a AND (b OR c). An empty
+// filter returns nil.
+func ParseFilter(filter string, fields Fields) (Expr, error) {
+	if strings.TrimSpace(filter) == "" {
+		return nil, nil
+	}
+	if len(filter) > maxFilterLength {
+		return nil, fmt.Errorf("longer than %d bytes", maxFilterLength)
+	}
+	toks, err := lex(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, fields: fields}
+	e, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q", t.text)
+	}
+	return e, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokText
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokMinus
+)
+
+type token struct {
+	kind tokKind
+	text string
+}
+
+func lex(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			toks = append(toks, token{tokLParen, "("})
+			i++
+		case c == ')':
+			toks = append(toks, token{tokRParen, ")"})
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, errors.New("unterminated string")
+			}
+			v, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s", s[i:j+1])
+			}
+			toks = append(toks, token{tokString, v})
+			i = j + 1
+		case strings.HasPrefix(s[i:], "<=") || strings.HasPrefix(s[i:], ">=") || strings.HasPrefix(s[i:], "!="):
+			toks = append(toks, token{tokOp, s[i : i+2]})
+			i += 2
+		case c == '=' || c == '<' || c == '>' || c == ':':
+			toks = append(toks, token{tokOp, s[i : i+1]})
+			i++
+		case c == '-' && (i+1 >= len(s) || !isDigit(s[i+1])):
+			toks = append(toks, token{tokMinus, "-"})
+			i++
+		default:
+			j := i
+			for j < len(s) {
+				r, size := utf8.DecodeRuneInString(s[j:])
+				if !isText(r) {
+					break
+				}
+				j += size
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q", string(c))
+			}
+			toks = append(toks, token{tokText, s[i:j]})
+			i = j
+		}
+	}
+	return append(toks, token{kind: tokEOF}), nil
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isText(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.*-+", r)
+}
+
+// maxDepth bounds the nesting of parentheses.
+const maxDepth = 32
+
+type parser struct {
+	toks   []token
+	pos    int
+	fields Fields
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == tokText && t.text == kw {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expression = sequence { "AND" sequence }
+// sequence   = factor { factor }
+func (p *parser) expression(depth int) (Expr, error) {
+	if depth > maxDepth {
+		return nil, errors.New("nested too deeply")
+	}
+	var and And
+	for {
+		f, err := p.factor(depth)
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, f)
+		if p.keyword("AND") {
+			continue
+		}
+		if t := p.peek(); t.kind == tokEOF || t.kind == tokRParen {
+			break
+		}
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+// factor = term { "OR" term }
+func (p *parser) factor(depth int) (Expr, error) {
+	var or Or
+	for {
+		t, err := p.term(depth)
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, t)
+		if !p.keyword("OR") {
+			break
+		}
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+// term = [ "NOT" | "-" ] ( "(" expression ")" | restriction )
+func (p *parser) term(depth int) (Expr, error) {
+	negated := p.keyword("NOT")
+	if !negated && p.peek().kind == tokMinus {
+		p.next()
+		negated = true
+	}
+	if negated {
+		e, err := p.term(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Not{e}, nil
+	}
+	if p.peek().kind == tokLParen {
+		p.next()
+		e, err := p.expression(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokRParen {
+			return nil, errors.New("missing )")
+		}
+		return e, nil
+	}
+	return p.restriction()
+}
+
+// restriction = field comparator value
+func (p *parser) restriction() (Expr, error) {
+	f := p.next()
+	if f.kind != tokText {
+		if f.kind == tokEOF {
+			return nil, errors.New("unexpected end")
+		}
+		return nil, fmt.Errorf("unexpected %q", f.text)
+	}
+	typ, ok := p.fields[f.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", f.text)
+	}
+	op := p.next()
+	if op.kind != tokOp {
+		return nil, fmt.Errorf("missing comparator after %q", f.text)
+	}
+	v := p.next()
+	if v.kind != tokText && v.kind != tokString {
+		return nil, fmt.Errorf("missing value after %s %s", f.text, op.text)
+	}
+	switch op.text {
+	case ":":
+		if typ != String {
+			return nil, fmt.Errorf("%s: only string fields support :", f.text)
+		}
+	case "<", "<=", ">", ">=":
+		if typ == Bool {
+			return nil, fmt.Errorf("%s: booleans only support = and !=", f.text)
+		}
+	}
+	value, err := parseValue(v.text, typ)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.text, err)
+	}
+	return Compare{Field: f.text, Op: op.text, Value: value}, nil
+}
+
+func parseValue(s string, typ Type) (any, error) {
+	switch typ {
+	case Int:
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", s)
+		}
+		return v, nil
+	case Float:
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", s)
+		}
+		return v, nil
+	case Bool:
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", s)
+		}
+		return v, nil
+	case Timestamp:
+		v, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an RFC 3339 timestamp", s)
+		}
+		return v, nil
+	}
+	return s, nil
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// Match reports whether the item whose fields are read by get matches e. A
+// nil e matches every item.
+func Match(e Expr, get func(field string) any) bool {
+	switch e := e.(type) {
+	case nil:
+		return true
+	case And:
+		for _, x := range e {
+			if !Match(x, get) {
+				return false
+			}
+		}
+		return true
+	case Or:
+		for _, x := range e {
+			if Match(x, get) {
+				return true
+			}
+		}
+		return false
+	case Not:
+		return !Match(e.Expr, get)
+	case Compare:
+		return e.match(get(e.Field))
+	}
+	return false
+}
+
+func (e Compare) match(v any) bool {
+	if s, ok := v.(string); ok {
+		want := e.Value.(string)
+		switch e.Op {
+		case ":":
+			return strings.Contains(s, want)
+		case "=", "!=":
+			return wildcard(s, want) == (e.Op == "=")
+		}
+	}
+	c, ok := compare(v, e.Value)
+	if !ok {
+		return false
+	}
+	switch e.Op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// wildcard matches s against pattern with an optional leading or trailing *.
+func wildcard(s, pattern string) bool {
+	leading, trailing := strings.HasPrefix(pattern, "*"), strings.HasSuffix(pattern, "*")
+	switch {
+	case leading && trailing && len(pattern) > 1:
+		return strings.Contains(s, pattern[1:len(pattern)-1])
+	case trailing:
+		return strings.HasPrefix(s, strings.TrimSuffix(pattern, "*"))
+	case leading:
+		return strings.HasSuffix(s, strings.TrimPrefix(pattern, "*"))
+	}
+	return s == pattern
+}
+
+// compare orders two values of the same field type.
+func compare(a, b any) (int, bool) {
+	switch a := a.(type) {
+	case string:
+		b, ok := b.(string)
+		return strings.Compare(a, b), ok
+	case int64:
+		b, ok := b.(int64)
+		return cmp.Compare(a, b), ok
+	case float64:
+		b, ok := b.(float64)
+		return cmp.Compare(a, b), ok
+	case bool:
+		b, ok := b.(bool)
+		switch {
+		case !ok || a == b:
+			return 0, ok
+		case !a:
+			return -1, true
+		}
+		return 1, true
+	case time.Time:
+		b, ok := b.(time.Time)
+		return a.Compare(b), ok
+	}
+	return 0, false
+}
@@ -0,0 +1,73 @@
+package listing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testFields = Fields{"name": String, "count": Int, "vip": Bool, "create_time": Timestamp}
+
+func TestParseFilter(t *testing.T) {
+	for filter, want := range map[string]string{
+		`name = "World"`:                            `name = "World"`,
+		`name=World count > 2`:                      `(name = "World") AND (count > "2")`,
+		`name:or AND count >= 1 OR vip = true`:      `(name : "or") AND ((count >= "1") OR (vip = "true"))`,
+		`NOT (name = a OR name = b)`:                `NOT (name = "a") OR (name = "b")`,
+		`-vip = false`:                              `NOT vip = "false"`,
+		`create_time > "2026-01-02T15:04:05+02:00"`: `create_time > "2026-01-02T13:04:05Z"`,
+		`count < -3`:                                `count < "-3"`,
+		`name = "Grüße"`:                            `name = "Grüße"`,
+	} {
+		e, err := ParseFilter(filter, testFields)
+		require.NoError(t, err, filter)
+		require.Equal(t, want, e.String(), filter)
+	}
+
+	e, err := ParseFilter("  ", testFields)
+	require.NoError(t, err)
+	require.Nil(t, e)
+
+	for filter, want := range map[string]string{
+		`age = 3`:             `unknown field "age"`,
+		`count = three`:       `count: "three" is not an integer`,
+		`vip < true`:          `vip: booleans only support = and !=`,
+		`count : 3`:           `count: only string fields support :`,
+		`(name = a`:           `missing )`,
+		`name =`:              `missing value after name =`,
+		`name`:                `missing comparator after "name"`,
+		`name = "a`:           `unterminated string`,
+		`name = a)`:           `unexpected ")"`,
+		`create_time > today`: `create_time: "today" is not an RFC 3339 timestamp`,
+	} {
+		_, err := ParseFilter(filter, testFields)
+		require.EqualError(t, err, want, filter)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	item := map[string]any{
+		"name": "Hello World", "count": int64(3), "vip": true,
+		"create_time": time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+	get := func(field string) any { return item[field] }
+	for filter, want := range map[string]bool{
+		`name = "Hello World"`:                  true,
+		`name = Hello*`:                         true,
+		`name = *World`:                         true,
+		`name = *lo*`:                           true,
+		`name != Hello*`:                        false,
+		`name : World`:                          true,
+		`name : world`:                          false,
+		`count > 2 count <= 3`:                  true,
+		`count > 3 OR vip = true`:               true,
+		`-vip = true`:                           false,
+		`create_time < "2026-01-02T00:00:01Z"`:  true,
+		`create_time >= "2026-01-03T00:00:00Z"`: false,
+	} {
+		e, err := ParseFilter(filter, testFields)
+		require.NoError(t, err, filter)
+		require.Equal(t, want, Match(e, get), filter)
+	}
+}
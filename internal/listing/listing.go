@@ -0,0 +1,178 @@
+// Package listing implements the conventions of list endpoints, the same for
+// List* RPCs and HTTP routes: page_size, opaque signed page_token cursors,
+// AIP-160 filter expressions and AIP-132 order_by.
+//
+// A collection declares the fields clients may filter and order by in a
+// Spec. A Lister parses requests against it into a Query, which a
+// repository translates into its own query language, or which Page applies
+// to items held in memory.
+package listing
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Type is the type of a field, it decides which literals and comparisons
+// filters may use on it.
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Float
+	Bool
+	// Timestamp literals are RFC 3339 strings, e.g.
+	// create_time > "2026-01-02T15:04:05Z".
+	Timestamp
+)
+
+// Fields are the fields of a collection by their name in the API, e.g.
+// "create_time".
+type Fields map[string]Type
+
+// Spec describes a collection.
+type Spec struct {
+	Fields Fields
+	// DefaultOrder applies when the request has no order_by, e.g.
+	// "create_time desc".
+	DefaultOrder string
+	// DefaultPageSize applies when the request has no page_size, 50 when
+	// zero.
+	DefaultPageSize int
+	// MaxPageSize caps page_size, 1000 when zero.
+	MaxPageSize int
+}
+
+// Request holds the list parameters of a request.
+type Request struct {
+	PageSize  int
+	PageToken string
+	Filter    string
+	OrderBy   string
+}
+
+// FromQuery reads the page_size, page_token, filter and order_by query
+// parameters of an HTTP request.
+func FromQuery(q url.Values) (Request, error) {
+	req := Request{PageToken: q.Get("page_token"), Filter: q.Get("filter"), OrderBy: q.Get("order_by")}
+	if v := q.Get("page_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return Request{}, errors.New("page_size must be an integer")
+		}
+		req.PageSize = n
+	}
+	return req, nil
+}
+
+// Query is a parsed list request.
+type Query struct {
+	// Size is the number of items of the page.
+	Size int
+	// Filter selects the items, nil selects all.
+	Filter Expr
+	// Order sorts the items. Items with equal values are ordered by their
+	// key, so pages do not overlap.
+	Order []OrderField
+	// After is the position of the page token, nil on the first page.
+	After *Cursor
+
+	fingerprint string
+}
+
+// Cursor is the position after an item: its values of the Order fields and
+// its unique key.
+type Cursor struct {
+	Values []any
+	Key    string
+}
+
+// Lister parses the list requests of a collection.
+type Lister struct {
+	spec   Spec
+	tokens *Tokens
+}
+
+// NewLister returns a Lister of the collection described by spec whose page
+// tokens are signed by tokens.
+func NewLister(spec Spec, tokens *Tokens) *Lister {
+	if spec.DefaultPageSize <= 0 {
+		spec.DefaultPageSize = 50
+	}
+	if spec.MaxPageSize <= 0 {
+		spec.MaxPageSize = 1000
+	}
+	return &Lister{spec: spec, tokens: tokens}
+}
+
+// Parse validates req. Its errors describe what is wrong with the request
+// and are meant to be returned to the client as invalid arguments.
+func (l *Lister) Parse(req Request) (*Query, error) {
+	q := &Query{Size: req.PageSize}
+	switch {
+	case q.Size < 0:
+		return nil, errors.New("page_size must not be negative")
+	case q.Size == 0:
+		q.Size = l.spec.DefaultPageSize
+	case q.Size > l.spec.MaxPageSize:
+		q.Size = l.spec.MaxPageSize
+	}
+
+	var err error
+	if q.Filter, err = ParseFilter(req.Filter, l.spec.Fields); err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	orderBy := req.OrderBy
+	if orderBy == "" {
+		orderBy = l.spec.DefaultOrder
+	}
+	if q.Order, err = ParseOrderBy(orderBy, l.spec.Fields); err != nil {
+		return nil, fmt.Errorf("invalid order_by: %w", err)
+	}
+
+	// a token is only valid with the filter and order it was issued for
+	canonical := orderString(q.Order)
+	if q.Filter != nil {
+		canonical = q.Filter.String() + "\n" + canonical
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	q.fingerprint = hex.EncodeToString(sum[:8])
+
+	if req.PageToken != "" {
+		if q.After, err = l.decodeCursor(req.PageToken, q); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// NextPageToken returns the token of the page after c.
+func (l *Lister) NextPageToken(q *Query, c Cursor) string {
+	values := make([]string, len(c.Values))
+	for i, v := range c.Values {
+		values[i] = formatValue(v)
+	}
+	return l.tokens.encode(tokenPayload{Query: q.fingerprint, Values: values, Key: c.Key})
+}
+
+func (l *Lister) decodeCursor(token string, q *Query) (*Cursor, error) {
+	p, err := l.tokens.decode(token)
+	if err != nil {
+		return nil, err
+	}
+	if p.Query != q.fingerprint || len(p.Values) != len(q.Order) {
+		return nil, errors.New("page_token does not match the filter and order_by of the request")
+	}
+	c := &Cursor{Key: p.Key, Values: make([]any, len(p.Values))}
+	for i, v := range p.Values {
+		if c.Values[i], err = parseValue(v, l.spec.Fields[q.Order[i].Field]); err != nil {
+			return nil, errors.New("invalid page_token")
+		}
+	}
+	return c, nil
+}
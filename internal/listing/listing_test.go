@@ -0,0 +1,113 @@
+package listing
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	name  string
+	count int64
+}
+
+func getItem(it item, field string) any {
+	if field == "count" {
+		return it.count
+	}
+	return it.name
+}
+
+func keyItem(it item) string { return it.name }
+
+func TestLister(t *testing.T) {
+	tokens := NewTokens([]byte("secret"), 0)
+	l := NewLister(Spec{Fields: testFields, DefaultOrder: "count desc", DefaultPageSize: 2, MaxPageSize: 3}, tokens)
+
+	var items []item
+	for i := range 7 {
+		items = append(items, item{name: "item" + strconv.Itoa(i), count: int64(i % 3)})
+	}
+
+	list := func(req Request) ([]string, string) {
+		t.Helper()
+		q, err := l.Parse(req)
+		require.NoError(t, err)
+		page, next := Page(items, q, getItem, keyItem)
+		var names []string
+		for _, it := range page {
+			names = append(names, it.name)
+		}
+		if next == nil {
+			return names, ""
+		}
+		return names, l.NextPageToken(q, *next)
+	}
+
+	// count desc, then key
+	var all []string
+	req := Request{}
+	for {
+		names, token := list(req)
+		require.LessOrEqual(t, len(names), 2)
+		all = append(all, names...)
+		if token == "" {
+			break
+		}
+		req.PageToken = token
+	}
+	require.Equal(t, []string{"item2", "item5", "item1", "item4", "item0", "item3", "item6"}, all)
+
+	names, token := list(Request{PageSize: 100, Filter: "count >= 1", OrderBy: "name desc"})
+	require.Equal(t, []string{"item5", "item4", "item2"}, names, "page_size is capped")
+	names, token = list(Request{PageSize: 100, Filter: "count >= 1", OrderBy: "name desc", PageToken: token})
+	require.Equal(t, []string{"item1"}, names)
+	require.Empty(t, token)
+
+	_, token = list(Request{Filter: "count >= 1"})
+	require.NotEmpty(t, token)
+	for req, want := range map[Request]string{
+		{PageSize: -1}:                          "page_size must not be negative",
+		{Filter: "nope = 1"}:                    `invalid filter: unknown field "nope"`,
+		{OrderBy: "name sideways"}:              `invalid order_by: invalid "name sideways", want a field optionally followed by desc`,
+		{OrderBy: "name, name desc"}:            `invalid order_by: field "name" ordered twice`,
+		{OrderBy: "name,"}:                      `invalid order_by: empty field in "name,"`,
+		{PageToken: "forged"}:                   "invalid page_token",
+		{PageToken: token + "x"}:                "invalid page_token",
+		{PageToken: token, Filter: "count = 2"}: "page_token does not match the filter and order_by of the request",
+	} {
+		_, err := l.Parse(req)
+		require.EqualError(t, err, want, req)
+	}
+
+	other := NewLister(Spec{Fields: testFields, DefaultOrder: "count desc"}, NewTokens([]byte("other"), 0))
+	_, err := other.Parse(Request{PageToken: token, Filter: "count >= 1"})
+	require.EqualError(t, err, "invalid page_token", "signed with another key")
+}
+
+func TestTokens_expiry(t *testing.T) {
+	tokens := NewTokens(nil, time.Minute)
+	now := time.Now()
+	tokens.now = func() time.Time { return now }
+	token := tokens.encode(tokenPayload{Key: "a"})
+
+	p, err := tokens.decode(token)
+	require.NoError(t, err)
+	require.Equal(t, "a", p.Key)
+
+	now = now.Add(2 * time.Minute)
+	_, err = tokens.decode(token)
+	require.EqualError(t, err, "page_token expired")
+}
+
+func TestFromQuery(t *testing.T) {
+	req, err := FromQuery(url.Values{"page_size": {"10"}, "page_token": {"t"}, "filter": {"a = 1"}, "order_by": {"a desc"}})
+	require.NoError(t, err)
+	require.Equal(t, Request{PageSize: 10, PageToken: "t", Filter: "a = 1", OrderBy: "a desc"}, req)
+
+	_, err = FromQuery(url.Values{"page_size": {"ten"}})
+	require.EqualError(t, err, "page_size must be an integer")
+}
@@ -0,0 +1,58 @@
+package listing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OrderField is a field of an order_by.
+type OrderField struct {
+	Field string
+	Desc  bool
+}
+
+// ParseOrderBy parses an AIP-132 order_by on fields: a comma separated list
+// of field names, each optionally followed by "desc", e.g.
+// "create_time desc, name".
+func ParseOrderBy(orderBy string, fields Fields) ([]OrderField, error) {
+	var order []OrderField
+	seen := map[string]bool{}
+	for _, part := range strings.Split(orderBy, ",") {
+		words := strings.Fields(part)
+		if len(words) == 0 {
+			if strings.TrimSpace(orderBy) == "" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("empty field in %q", orderBy)
+		}
+		f := OrderField{Field: words[0]}
+		if _, ok := fields[f.Field]; !ok {
+			return nil, fmt.Errorf("unknown field %q", f.Field)
+		}
+		if seen[f.Field] {
+			return nil, fmt.Errorf("field %q ordered twice", f.Field)
+		}
+		seen[f.Field] = true
+		switch {
+		case len(words) == 1:
+		case len(words) == 2 && words[1] == "desc":
+			f.Desc = true
+		case len(words) == 2 && words[1] == "asc":
+		default:
+			return nil, fmt.Errorf("invalid %q, want a field optionally followed by desc", strings.TrimSpace(part))
+		}
+		order = append(order, f)
+	}
+	return order, nil
+}
+
+func orderString(order []OrderField) string {
+	parts := make([]string, len(order))
+	for i, f := range order {
+		parts[i] = f.Field
+		if f.Desc {
+			parts[i] += " desc"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
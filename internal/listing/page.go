@@ -0,0 +1,56 @@
+package listing
+
+import (
+	"slices"
+	"strings"
+)
+
+// Page applies q to items held in memory. get reads a field of an item and
+// key returns its unique key. It returns the items of the page and, when
+// more follow, the cursor of the next page.
+func Page[T any](items []T, q *Query, get func(item T, field string) any, key func(item T) string) ([]T, *Cursor) {
+	type entry struct {
+		item   T
+		cursor Cursor
+	}
+	var entries []entry
+	for _, item := range items {
+		if !Match(q.Filter, func(field string) any { return get(item, field) }) {
+			continue
+		}
+		c := Cursor{Key: key(item), Values: make([]any, len(q.Order))}
+		for i, f := range q.Order {
+			c.Values[i] = get(item, f.Field)
+		}
+		if q.After != nil && q.compare(c, *q.After) <= 0 {
+			continue
+		}
+		entries = append(entries, entry{item: item, cursor: c})
+	}
+	slices.SortFunc(entries, func(a, b entry) int { return q.compare(a.cursor, b.cursor) })
+
+	var next *Cursor
+	if len(entries) > q.Size {
+		entries = entries[:q.Size]
+		next = &entries[len(entries)-1].cursor
+	}
+	page := make([]T, len(entries))
+	for i, e := range entries {
+		page[i] = e.item
+	}
+	return page, next
+}
+
+// compare orders two positions by the Order fields, then by key.
+func (q *Query) compare(a, b Cursor) int {
+	for i, f := range q.Order {
+		c, _ := compare(a.Values[i], b.Values[i])
+		if f.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a.Key, b.Key)
+}
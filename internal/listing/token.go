@@ -0,0 +1,78 @@
+package listing
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var errInvalidToken = errors.New("invalid page_token")
+
+// Tokens signs page tokens, so clients cannot forge cursors. Replicas must
+// share the key for tokens to survive a request landing on another one.
+type Tokens struct {
+	key []byte
+	ttl time.Duration
+	now func() time.Time
+}
+
+// NewTokens returns Tokens signing with key whose tokens expire after ttl, 0
+// for never. An empty key is replaced with a random one, tokens are then
+// only valid within this process.
+func NewTokens(key []byte, ttl time.Duration) *Tokens {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		_, _ = rand.Read(key)
+	}
+	return &Tokens{key: key, ttl: ttl, now: time.Now}
+}
+
+type tokenPayload struct {
+	// Query is the fingerprint of the filter and order.
+	Query  string   `json:"q"`
+	Values []string `json:"v,omitempty"`
+	Key    string   `json:"k"`
+	// Expires is a Unix time, 0 for never.
+	Expires int64 `json:"x,omitempty"`
+}
+
+// encode returns "<payload>.<signature>", both base64url.
+func (t *Tokens) encode(p tokenPayload) string {
+	if t.ttl > 0 {
+		p.Expires = t.now().Add(t.ttl).Unix()
+	}
+	b, _ := json.Marshal(p)
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(t.sign(payload))
+}
+
+func (t *Tokens) decode(token string) (tokenPayload, error) {
+	var p tokenPayload
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return p, errInvalidToken
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, t.sign(payload)) {
+		return p, errInvalidToken
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(b, &p) != nil {
+		return p, errInvalidToken
+	}
+	if p.Expires != 0 && t.now().Unix() > p.Expires {
+		return p, errors.New("page_token expired")
+	}
+	return p, nil
+}
+
+func (t *Tokens) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)[:16]
+}
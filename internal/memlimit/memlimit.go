@@ -0,0 +1,165 @@
+// Package memlimit sets the Go runtime soft memory limit (GOMEMLIMIT) from
+// the cgroup memory limit of the container, the memory counterpart of
+// go.uber.org/automaxprocs.
+package memlimit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const _memLimitKey = "GOMEMLIMIT"
+
+// cgroup v1 reports "no limit" as a page aligned math.MaxInt64.
+const _unlimited = 1 << 62
+
+type config struct {
+	printf     func(string, ...interface{})
+	ratio      float64
+	cgroupRoot string
+	procCgroup string
+}
+
+func (c *config) log(fmt string, args ...interface{}) {
+	if c.printf != nil {
+		c.printf(fmt, args...)
+	}
+}
+
+// Option configures Set.
+type Option interface {
+	apply(*config)
+}
+
+type optionFunc func(*config)
+
+func (of optionFunc) apply(cfg *config) { of(cfg) }
+
+// Logger uses the supplied printf implementation for log output.
+// By default, Set doesn't log anything.
+func Logger(printf func(string, ...interface{})) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.printf = printf
+	})
+}
+
+// Ratio sets the share of the cgroup memory limit given to the Go heap,
+// leaving the rest as headroom for non-heap memory. It must be in (0, 1];
+// zero or less disables Set.
+func Ratio(r float64) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.ratio = min(r, 1)
+	})
+}
+
+// Set sets GOMEMLIMIT to Ratio of the cgroup memory limit. It returns a
+// function to restore the previous limit. A GOMEMLIMIT environment variable
+// is honored and left untouched.
+func Set(opts ...Option) (func(), error) {
+	cfg := &config{
+		ratio:      0.9,
+		cgroupRoot: "/sys/fs/cgroup",
+		procCgroup: "/proc/self/cgroup",
+	}
+	for _, o := range opts {
+		o.apply(cfg)
+	}
+
+	undoNoop := func() {
+		cfg.log("memlimit: No GOMEMLIMIT change to reset")
+	}
+
+	if limit, exists := os.LookupEnv(_memLimitKey); exists {
+		cfg.log("memlimit: Honoring GOMEMLIMIT=%q as set in environment", limit)
+		return undoNoop, nil
+	}
+	if cfg.ratio <= 0 {
+		cfg.log("memlimit: Leaving GOMEMLIMIT=%v: disabled", debug.SetMemoryLimit(-1))
+		return undoNoop, nil
+	}
+
+	cgroupLimit, err := cgroupMemoryLimit(cfg.cgroupRoot, cfg.procCgroup)
+	if err != nil {
+		return undoNoop, err
+	}
+	if cgroupLimit == 0 {
+		cfg.log("memlimit: Leaving GOMEMLIMIT=%v: memory limit undefined", debug.SetMemoryLimit(-1))
+		return undoNoop, nil
+	}
+
+	limit := int64(float64(cgroupLimit) * cfg.ratio)
+	prev := debug.SetMemoryLimit(limit)
+	cfg.log("memlimit: Updating GOMEMLIMIT=%v: %v of cgroup memory limit %v", limit, cfg.ratio, cgroupLimit)
+	return func() {
+		cfg.log("memlimit: Resetting GOMEMLIMIT to %v", prev)
+		debug.SetMemoryLimit(prev)
+	}, nil
+}
+
+// cgroupMemoryLimit returns the memory limit in bytes of the cgroup the
+// process belongs to, or 0 when there is none. cgroup v2 is tried first,
+// then v1; for each the process' own cgroup is preferred over the mount
+// root, which is what containers with a private cgroup namespace see.
+func cgroupMemoryLimit(root, procCgroup string) (int64, error) {
+	v1Path, v2Path := "/", "/"
+	if data, err := os.ReadFile(procCgroup); err == nil {
+		v1Path, v2Path = parseProcCgroup(string(data))
+	}
+
+	candidates := []string{
+		filepath.Join(root, v2Path, "memory.max"),
+		filepath.Join(root, "memory.max"),
+		filepath.Join(root, "memory", v1Path, "memory.limit_in_bytes"),
+		filepath.Join(root, "memory", "memory.limit_in_bytes"),
+	}
+	for _, name := range candidates {
+		data, err := os.ReadFile(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		return parseLimit(string(data))
+	}
+	return 0, nil
+}
+
+// parseProcCgroup extracts the memory controller path (v1) and the unified
+// hierarchy path (v2) from the contents of /proc/self/cgroup.
+func parseProcCgroup(data string) (v1, v2 string) {
+	v1, v2 = "/", "/"
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		switch {
+		case parts[0] == "0" && parts[1] == "":
+			v2 = parts[2]
+		case slices.Contains(strings.Split(parts[1], ","), "memory"):
+			v1 = parts[2]
+		}
+	}
+	return v1, v2
+}
+
+func parseLimit(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "max" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 || n >= _unlimited {
+		return 0, nil
+	}
+	return n, nil
+}
@@ -0,0 +1,101 @@
+package memlimit
+
+import (
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, data string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(name), 0o755))
+	require.NoError(t, os.WriteFile(name, []byte(data), 0o600))
+}
+
+func TestCgroupMemoryLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  int64
+	}{
+		{
+			name:  "v2 namespaced",
+			files: map[string]string{"cgroup/memory.max": "134217728\n"},
+			want:  134217728,
+		},
+		{
+			name: "v2 nested",
+			files: map[string]string{
+				"proc":                            "0::/kubepods/pod1\n",
+				"cgroup/kubepods/pod1/memory.max": "268435456\n",
+			},
+			want: 268435456,
+		},
+		{
+			name:  "v2 unlimited",
+			files: map[string]string{"cgroup/memory.max": "max\n"},
+		},
+		{
+			name: "v1 nested",
+			files: map[string]string{
+				"proc": "12:cpu,cpuacct:/docker/abc\n11:memory:/docker/abc\n",
+				"cgroup/memory/docker/abc/memory.limit_in_bytes": "536870912\n",
+			},
+			want: 536870912,
+		},
+		{
+			name:  "v1 unlimited",
+			files: map[string]string{"cgroup/memory/memory.limit_in_bytes": "9223372036854771712\n"},
+		},
+		{
+			name: "no cgroup",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, data := range tt.files {
+				writeFile(t, filepath.Join(dir, name), data)
+			}
+			got, err := cgroupMemoryLimit(filepath.Join(dir, "cgroup"), filepath.Join(dir, "proc"))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "memory.max"), "1000000\n")
+	withCgroup := optionFunc(func(cfg *config) {
+		cfg.cgroupRoot = dir
+		cfg.procCgroup = filepath.Join(dir, "proc")
+	})
+	prev := debug.SetMemoryLimit(-1)
+
+	t.Run("ratio", func(t *testing.T) {
+		undo, err := Set(withCgroup, Ratio(0.5))
+		require.NoError(t, err)
+		require.Equal(t, int64(500000), debug.SetMemoryLimit(-1))
+		undo()
+		require.Equal(t, prev, debug.SetMemoryLimit(-1))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		_, err := Set(withCgroup, Ratio(0))
+		require.NoError(t, err)
+		require.Equal(t, prev, debug.SetMemoryLimit(-1))
+	})
+
+	t.Run("env override", func(t *testing.T) {
+		t.Setenv("GOMEMLIMIT", "64MiB")
+		var logged string
+		_, err := Set(withCgroup, Logger(func(s string, _ ...interface{}) { logged = s }))
+		require.NoError(t, err)
+		require.Contains(t, logged, "Honoring GOMEMLIMIT")
+		require.Equal(t, prev, debug.SetMemoryLimit(-1))
+	})
+}
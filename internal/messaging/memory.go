@@ -0,0 +1,168 @@
+package messaging
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// redeliveryDelay is how long a MemoryBroker waits before redelivering
+	// a message its handler failed, and delivering the messages after it.
+	redeliveryDelay = 100 * time.Millisecond
+	// maxRetained is the number of consumed messages a topic keeps for the
+	// groups subscribing later.
+	maxRetained = 1000
+)
+
+// MemoryBroker is a Publisher and Subscriber local to the process. A topic
+// keeps its last maxRetained messages and those a group has not consumed
+// yet, older ones are dropped. A new group starts from the first message
+// kept, so tests may publish before subscribing. A message failed by its
+// handler is redelivered after a delay, during which the group is held
+// back. Messages are lost on restart and the delivery order of a key is
+// kept only with one subscription per group.
+type MemoryBroker struct {
+	mu     sync.Mutex
+	topics map[string]*memoryTopic
+}
+
+type memoryTopic struct {
+	log []*Message
+	// first is the offset of log[0], the messages before it were dropped
+	first  int
+	groups map[string]*memoryGroup
+	// notify is closed and replaced when messages are added
+	notify chan struct{}
+}
+
+type memoryGroup struct {
+	// next is the offset in the log of the next message to deliver
+	next int
+	// redeliver holds the failed messages, delivered before the log
+	redeliver []*Message
+	// retryAt holds back the group until the failed messages are due
+	retryAt time.Time
+}
+
+// NewMemoryBroker returns a MemoryBroker without topics.
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{topics: map[string]*memoryTopic{}}
+}
+
+func (b *MemoryBroker) Publish(_ context.Context, msg *Message) error {
+	m := clone(msg)
+	if m.ID == "" {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return err
+		}
+		m.ID = id.String()
+	}
+	if m.Time.IsZero() {
+		m.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topic(m.Topic)
+	t.log = append(t.log, m)
+	t.trim()
+	t.broadcast()
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, topic, group string, h Handler) error {
+	for {
+		b.mu.Lock()
+		t := b.topic(topic)
+		g, ok := t.groups[group]
+		if !ok {
+			g = &memoryGroup{next: t.first}
+			t.groups[group] = g
+		}
+		if wait := time.Until(g.retryAt); wait > 0 {
+			b.mu.Unlock()
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			case <-timer.C:
+				continue
+			}
+		}
+		var msg *Message
+		switch {
+		case len(g.redeliver) > 0:
+			msg, g.redeliver = g.redeliver[0], g.redeliver[1:]
+		case g.next < t.first+len(t.log):
+			msg = t.log[g.next-t.first]
+			g.next++
+			t.trim()
+		}
+		notify := t.notify
+		b.mu.Unlock()
+
+		if msg == nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-notify:
+				continue
+			}
+		}
+		if err := h(context.WithoutCancel(ctx), clone(msg)); err != nil {
+			b.mu.Lock()
+			g.redeliver = append(g.redeliver, msg)
+			g.retryAt = time.Now().Add(redeliveryDelay)
+			b.mu.Unlock()
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// topic returns the topic named name, creating it. b.mu is held.
+func (b *MemoryBroker) topic(name string) *memoryTopic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &memoryTopic{groups: map[string]*memoryGroup{}, notify: make(chan struct{})}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// trim drops the messages every group has consumed, except the last
+// maxRetained.
+func (t *memoryTopic) trim() {
+	offset := t.first + len(t.log) - maxRetained
+	for _, g := range t.groups {
+		offset = min(offset, g.next)
+	}
+	n := offset - t.first
+	if n <= 0 {
+		return
+	}
+	// the dropped messages are released once the log is reallocated
+	clear(t.log[:n])
+	t.log = t.log[n:]
+	t.first = offset
+}
+
+func (t *memoryTopic) broadcast() {
+	close(t.notify)
+	t.notify = make(chan struct{})
+}
+
+// clone copies msg, so that handlers and publishers may modify theirs.
+func clone(msg *Message) *Message {
+	m := *msg
+	m.Headers = maps.Clone(msg.Headers)
+	m.Payload = append([]byte(nil), msg.Payload...)
+	return &m
+}
@@ -0,0 +1,165 @@
+// Package messaging publishes and consumes messages of a broker. Drivers,
+// e.g. Kafka or NATS, implement Publisher and Subscriber; MemoryBroker is
+// the in-process driver used by default and in tests. A Consumer runs a
+// Handler for a topic in a consumer group as a lifecycle component, wrapped
+// in Middleware for logging, tracing, metrics, retries and dead-lettering.
+package messaging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Message is a message of a topic.
+type Message struct {
+	// ID identifies the message, publishers set it when empty.
+	ID    string
+	Topic string
+	// Key orders messages: drivers deliver the messages of a key in the
+	// order they were published.
+	Key string
+	// Headers carry metadata, e.g. the trace context.
+	Headers map[string]string
+	Payload []byte
+	// Time is when the message was published, publishers set it when zero.
+	Time time.Time
+}
+
+// Publisher publishes messages.
+type Publisher interface {
+	// Publish returns once the broker has stored msg.
+	Publish(ctx context.Context, msg *Message) error
+}
+
+// Handler handles a message. Returning nil acknowledges it, an error has
+// the driver redeliver it.
+type Handler func(ctx context.Context, msg *Message) error
+
+// Middleware wraps a Handler.
+type Middleware func(Handler) Handler
+
+// Subscriber delivers messages to consumer groups. Every group gets each
+// message of a topic, which is handled by one of the subscriptions of the
+// group.
+type Subscriber interface {
+	// Subscribe joins group and passes the messages of topic to h, one at a
+	// time, until ctx is done and the message being handled is
+	// acknowledged. The context of h is not canceled with ctx, so that
+	// messages in progress complete on shutdown.
+	Subscribe(ctx context.Context, topic, group string, h Handler) error
+}
+
+// permanentError is a handler error not worth retrying.
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, e.g. for a malformed message:
+// Retry gives up at once and DeadLetter sets the message aside.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	return errors.As(err, &permanentError{})
+}
+
+type groupKey struct{}
+
+// Group returns the consumer group a Consumer handles the message of ctx
+// for, used by middleware to label logs and metrics.
+func Group(ctx context.Context) string {
+	g, _ := ctx.Value(groupKey{}).(string)
+	return g
+}
+
+// Option configures a Consumer.
+type Option interface {
+	apply(*Consumer)
+}
+
+type optionFunc func(*Consumer)
+
+func (f optionFunc) apply(c *Consumer) { f(c) }
+
+// WithMiddleware wraps the handler of the Consumer in mws, the first one
+// outermost.
+func WithMiddleware(mws ...Middleware) Option {
+	return optionFunc(func(c *Consumer) {
+		c.middleware = append(c.middleware, mws...)
+	})
+}
+
+// WithConcurrency sets the number of subscriptions of the Consumer, which
+// handle messages in parallel. 1 by default.
+func WithConcurrency(n int) Option {
+	return optionFunc(func(c *Consumer) {
+		c.concurrency = max(n, 1)
+	})
+}
+
+// Consumer handles the messages of a topic in a consumer group. It runs as
+// a lifecycle component: once stopped it leaves the group after the
+// messages in progress are handled.
+type Consumer struct {
+	l           *slog.Logger
+	sub         Subscriber
+	topic       string
+	group       string
+	h           Handler
+	middleware  []Middleware
+	concurrency int
+}
+
+// NewConsumer returns a Consumer passing the messages of topic to h.
+func NewConsumer(l *slog.Logger, sub Subscriber, topic, group string, h Handler, opts ...Option) *Consumer {
+	c := &Consumer{l: l, sub: sub, topic: topic, group: group, h: h, concurrency: 1}
+	for _, o := range opts {
+		o.apply(c)
+	}
+	return c
+}
+
+// Name returns the name of the Consumer, its topic and group.
+func (c *Consumer) Name() string {
+	return c.topic + "/" + c.group
+}
+
+// Run subscribes until ctx is done. A subscription failing stops the
+// others and its error is returned.
+func (c *Consumer) Run(ctx context.Context) error {
+	h := c.h
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		h = c.middleware[i](h)
+	}
+	inner := h
+	h = func(ctx context.Context, msg *Message) error {
+		return inner(context.WithValue(ctx, groupKey{}, c.group), msg)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	var wg sync.WaitGroup
+	for range c.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.sub.Subscribe(ctx, c.topic, c.group, h); err != nil && ctx.Err() == nil {
+				c.l.Error("[MESSAGING] subscription failed", slog.String("topic", c.topic), slog.String("group", c.group), slog.Any("error", err))
+				cancel(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := context.Cause(ctx); !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ravilushqa/boilerplate/internal/recovery"
+)
+
+// consume runs c until the returned function is called.
+func consume(t *testing.T, c *Consumer) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- c.Run(ctx) }()
+	return func() {
+		cancel()
+		require.NoError(t, <-stopped)
+	}
+}
+
+// collect returns a handler sending the payloads it handles to the channel.
+func collect() (Handler, chan string) {
+	payloads := make(chan string, 10)
+	return func(_ context.Context, msg *Message) error {
+		payloads <- string(msg.Payload)
+		return nil
+	}, payloads
+}
+
+func receive(t *testing.T, payloads chan string, n int) []string {
+	var got []string
+	for range n {
+		select {
+		case p := <-payloads:
+			got = append(got, p)
+		case <-time.After(time.Second):
+			t.Fatalf("received %v, want %d messages", got, n)
+		}
+	}
+	return got
+}
+
+func TestConsumer(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBroker()
+	for _, p := range []string{"a", "b", "c"} {
+		require.NoError(t, b.Publish(ctx, &Message{Topic: "greetings", Payload: []byte(p)}))
+	}
+
+	// every group gets each message, once
+	billing, billed := collect()
+	stopBilling := consume(t, NewConsumer(slog.Default(), b, "greetings", "billing", billing, WithConcurrency(2)))
+	audit, audited := collect()
+	stopAudit := consume(t, NewConsumer(slog.Default(), b, "greetings", "audit", audit))
+	require.ElementsMatch(t, []string{"a", "b", "c"}, receive(t, billed, 3))
+	require.Equal(t, []string{"a", "b", "c"}, receive(t, audited, 3))
+
+	require.NoError(t, b.Publish(ctx, &Message{Topic: "greetings", Payload: []byte("d")}))
+	require.Equal(t, []string{"d"}, receive(t, billed, 1))
+	require.Equal(t, []string{"d"}, receive(t, audited, 1))
+	stopBilling()
+	stopAudit()
+	require.Empty(t, billed)
+}
+
+func TestConsumer_redelivery(t *testing.T) {
+	b := NewMemoryBroker()
+	var attempts atomic.Int32
+	handled := make(chan *Message, 1)
+	var group atomic.Value
+	stop := consume(t, NewConsumer(slog.Default(), b, "greetings", "billing", func(ctx context.Context, msg *Message) error {
+		group.Store(Group(ctx))
+		if attempts.Add(1) == 1 {
+			return errors.New("unavailable")
+		}
+		handled <- msg
+		return nil
+	}))
+	defer stop()
+
+	require.NoError(t, b.Publish(context.Background(), &Message{Topic: "greetings", Key: "k", Payload: []byte("a")}))
+	msg := <-handled
+	require.Equal(t, int32(2), attempts.Load())
+	require.Equal(t, "billing", group.Load())
+	require.NotEmpty(t, msg.ID)
+	require.False(t, msg.Time.IsZero())
+	require.Equal(t, "k", msg.Key)
+}
+
+func TestMemoryBroker_keyOrder(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBroker()
+	for _, p := range []string{"a", "b", "c"} {
+		require.NoError(t, b.Publish(ctx, &Message{Topic: "greetings", Key: "k", Payload: []byte(p)}))
+	}
+	// b waits for a, failed once, to be redelivered
+	handled := make(chan string, 10)
+	var failed atomic.Bool
+	stop := consume(t, NewConsumer(slog.Default(), b, "greetings", "billing", func(_ context.Context, msg *Message) error {
+		if string(msg.Payload) == "a" && !failed.Swap(true) {
+			return errors.New("unavailable")
+		}
+		handled <- string(msg.Payload)
+		return nil
+	}))
+	defer stop()
+	require.Equal(t, []string{"a", "b", "c"}, receive(t, handled, 3))
+}
+
+func TestMemoryBroker_retention(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBroker()
+	// kept reports the number of messages the topic keeps
+	kept := func() int {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return len(b.topic("greetings").log)
+	}
+	publish := func(n int) {
+		for i := range n {
+			require.NoError(t, b.Publish(ctx, &Message{Topic: "greetings", Payload: []byte(strconv.Itoa(i))}))
+		}
+	}
+
+	// without groups the topic keeps the latest messages
+	publish(maxRetained + 2)
+	require.Equal(t, maxRetained, kept())
+
+	// a group holds the messages it has not consumed yet
+	first, release := make(chan string, 1), make(chan struct{})
+	stop := consume(t, NewConsumer(slog.Default(), b, "greetings", "billing", func(_ context.Context, msg *Message) error {
+		select {
+		case first <- string(msg.Payload):
+		default:
+		}
+		<-release
+		return nil
+	}))
+	defer stop()
+	require.Equal(t, "2", <-first, "the first two were dropped")
+	publish(5)
+	require.Greater(t, kept(), maxRetained)
+
+	close(release)
+	require.Eventually(t, func() bool { return kept() == maxRetained }, time.Second, time.Millisecond)
+}
+
+type failingSubscriber struct{}
+
+func (failingSubscriber) Subscribe(context.Context, string, string, Handler) error {
+	return errors.New("broker unreachable")
+}
+
+func TestConsumer_failure(t *testing.T) {
+	c := NewConsumer(slog.Default(), failingSubscriber{}, "greetings", "billing", nil, WithConcurrency(3))
+	require.EqualError(t, c.Run(context.Background()), "broker unreachable")
+	require.Equal(t, "greetings/billing", c.Name())
+}
+
+func TestMiddleware_deadLetter(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBroker()
+	dead, deadPayloads := collect()
+	var deadMsgs sync.Map
+	stopDead := consume(t, NewConsumer(slog.Default(), b, "greetings.dead", "ops", func(ctx context.Context, msg *Message) error {
+		deadMsgs.Store(string(msg.Payload), msg)
+		return dead(ctx, msg)
+	}))
+	defer stopDead()
+
+	var attempts sync.Map
+	h := func(_ context.Context, msg *Message) error {
+		n, _ := attempts.LoadOrStore(string(msg.Payload), new(atomic.Int32))
+		n.(*atomic.Int32).Add(1)
+		switch string(msg.Payload) {
+		case "poison":
+			return errors.New("cannot handle")
+		case "malformed":
+			return Permanent(errors.New("malformed"))
+		case "panic":
+			panic("boom")
+		}
+		return nil
+	}
+	stop := consume(t, NewConsumer(slog.Default(), b, "greetings", "billing", h, WithMiddleware(
+		Tracing(),
+		Metrics(),
+		Logging(slog.Default()),
+		DeadLetter(b, "greetings.dead"),
+		Retry(3, time.Millisecond),
+		Recover(recovery.New(slog.Default(), nil)),
+	)))
+	defer stop()
+
+	for _, p := range []string{"ok", "poison", "malformed", "panic"} {
+		require.NoError(t, b.Publish(ctx, &Message{Topic: "greetings", Payload: []byte(p), Headers: map[string]string{"tenant": "t1"}}))
+	}
+	require.ElementsMatch(t, []string{"poison", "malformed", "panic"}, receive(t, deadPayloads, 3))
+
+	for p, want := range map[string]int32{"ok": 1, "poison": 3, "malformed": 1, "panic": 1} {
+		n, _ := attempts.Load(p)
+		require.Equal(t, want, n.(*atomic.Int32).Load(), p)
+	}
+	m, _ := deadMsgs.Load("poison")
+	msg := m.(*Message)
+	require.Equal(t, "cannot handle", msg.Headers[HeaderDeadLetterError])
+	require.Equal(t, "greetings", msg.Headers[HeaderDeadLetterTopic])
+	require.Equal(t, "billing", msg.Headers[HeaderDeadLetterGroup])
+	require.Equal(t, "t1", msg.Headers["tenant"])
+	m, _ = deadMsgs.Load("panic")
+	require.Contains(t, m.(*Message).Headers[HeaderDeadLetterError], "panic, correlation id ")
+}
+
+func TestMiddleware_tracing(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(prev)
+
+	b := NewMemoryBroker()
+	traces := make(chan trace.TraceID, 1)
+	stop := consume(t, NewConsumer(slog.Default(), b, "greetings", "billing", func(ctx context.Context, _ *Message) error {
+		traces <- trace.SpanContextFromContext(ctx).TraceID()
+		return nil
+	}, WithMiddleware(Tracing())))
+	defer stop()
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	require.NoError(t, TracingPublisher(b).Publish(ctx, &Message{Topic: "greetings"}))
+	require.Equal(t, sc.TraceID(), <-traces)
+}
@@ -0,0 +1,220 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ravilushqa/boilerplate/internal/recovery"
+)
+
+var (
+	handledMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "messaging_messages_handled_total",
+		Help: "Number of handled messages by topic, consumer group and result (success or failure).",
+	}, []string{"topic", "group", "result"})
+	handleDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "messaging_handle_duration_seconds",
+		Help:    "Duration of message handling by topic and consumer group.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic", "group"})
+	deadLetters = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "messaging_dead_letters_total",
+		Help: "Number of messages set aside to a dead-letter topic by topic and consumer group.",
+	}, []string{"topic", "group"})
+)
+
+func init() {
+	prometheus.MustRegister(handledMessages, handleDuration, deadLetters)
+}
+
+// Dead-letter headers describe why a message was set aside.
+const (
+	HeaderDeadLetterError = "dead-letter-error"
+	HeaderDeadLetterTopic = "dead-letter-topic"
+	HeaderDeadLetterGroup = "dead-letter-group"
+)
+
+// maxBackoff caps the wait of Retry between attempts.
+const maxBackoff = time.Minute
+
+const tracerName = "github.com/ravilushqa/boilerplate/internal/messaging"
+
+// Logging logs messages whose handling failed, and the others at debug
+// level.
+func Logging(l *slog.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			start := time.Now()
+			err := next(ctx, msg)
+			attrs := []slog.Attr{
+				slog.String("topic", msg.Topic),
+				slog.String("group", Group(ctx)),
+				slog.String("message_id", msg.ID),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if err != nil {
+				l.LogAttrs(ctx, slog.LevelError, "[MESSAGING] failed to handle message", append(attrs, slog.Any("error", err))...)
+				return err
+			}
+			l.LogAttrs(ctx, slog.LevelDebug, "[MESSAGING] handled message", attrs...)
+			return nil
+		}
+	}
+}
+
+// Metrics counts the handled messages and observes how long they take.
+func Metrics() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			start := time.Now()
+			err := next(ctx, msg)
+			group := Group(ctx)
+			handleDuration.WithLabelValues(msg.Topic, group).Observe(time.Since(start).Seconds())
+			result := "success"
+			if err != nil {
+				result = "failure"
+			}
+			handledMessages.WithLabelValues(msg.Topic, group, result).Inc()
+			return err
+		}
+	}
+}
+
+// Tracing handles every message in a consumer span, child of the span that
+// published it when a TracingPublisher did. Spans are recorded by the
+// global OpenTelemetry provider.
+func Tracing() Middleware {
+	tracer := otel.Tracer(tracerName)
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(msg.Headers))
+			ctx, span := tracer.Start(ctx, msg.Topic+" process",
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(
+					attribute.String("messaging.destination.name", msg.Topic),
+					attribute.String("messaging.consumer.group.name", Group(ctx)),
+					attribute.String("messaging.message.id", msg.ID),
+				))
+			defer span.End()
+			err := next(ctx, msg)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return err
+		}
+	}
+}
+
+type tracingPublisher struct {
+	next   Publisher
+	tracer trace.Tracer
+}
+
+// TracingPublisher publishes every message of p in a producer span whose
+// context is injected in the message headers, for Tracing to continue.
+func TracingPublisher(p Publisher) Publisher {
+	return &tracingPublisher{next: p, tracer: otel.Tracer(tracerName)}
+}
+
+func (p *tracingPublisher) Publish(ctx context.Context, msg *Message) error {
+	ctx, span := p.tracer.Start(ctx, msg.Topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("messaging.destination.name", msg.Topic)))
+	defer span.End()
+
+	m := *msg
+	m.Headers = make(map[string]string, len(msg.Headers)+2)
+	for k, v := range msg.Headers {
+		m.Headers[k] = v
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(m.Headers))
+	err := p.next.Publish(ctx, &m)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// Retry makes up to attempts attempts of a failing message in process,
+// waiting backoff after the first failure and doubling the wait after each
+// following one, before returning the error to the outer middleware or the
+// driver. Permanent errors are not retried.
+func Retry(attempts int, backoff time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			d := backoff
+			for attempt := 1; ; attempt++ {
+				err := next(ctx, msg)
+				if err == nil || attempt >= attempts || isPermanent(err) {
+					return err
+				}
+				t := time.NewTimer(d/2 + rand.N(d/2+1))
+				select {
+				case <-ctx.Done():
+					t.Stop()
+					return err
+				case <-t.C:
+				}
+				d = min(2*d, maxBackoff)
+			}
+		}
+	}
+}
+
+// DeadLetter publishes the messages failing the inner handler to topic
+// with the HeaderDeadLetter headers and acknowledges them, so that a
+// poison message does not block its consumer group. When publishing fails
+// the message is redelivered.
+func DeadLetter(p Publisher, topic string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			err := next(ctx, msg)
+			if err == nil {
+				return nil
+			}
+			dead := *msg
+			dead.Topic, dead.ID = topic, ""
+			dead.Headers = make(map[string]string, len(msg.Headers)+3)
+			for k, v := range msg.Headers {
+				dead.Headers[k] = v
+			}
+			dead.Headers[HeaderDeadLetterError] = err.Error()
+			dead.Headers[HeaderDeadLetterTopic] = msg.Topic
+			dead.Headers[HeaderDeadLetterGroup] = Group(ctx)
+			if pubErr := p.Publish(ctx, &dead); pubErr != nil {
+				return errors.Join(err, fmt.Errorf("publish to dead-letter topic: %w", pubErr))
+			}
+			deadLetters.WithLabelValues(msg.Topic, Group(ctx)).Inc()
+			return nil
+		}
+	}
+}
+
+// Recover turns a panicking handler into a Permanent error carrying the
+// correlation ID h logged it under.
+func Recover(h *recovery.Handler) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					id := h.Handle(ctx, p, "messaging", map[string]string{"topic": msg.Topic, "message_id": msg.ID})
+					err = Permanent(fmt.Errorf("panic, correlation id %s", id))
+				}
+			}()
+			return next(ctx, msg)
+		}
+	}
+}
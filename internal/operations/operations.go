@@ -0,0 +1,273 @@
+// Package operations runs work that outlives a request as long-running
+// operations. A handler starts a task with Manager.Start and returns the
+// operation to the client, which polls it, waits for it or cancels it through
+// the google.longrunning.Operations API the Manager implements. Operations
+// are kept in a Store, so replicas sharing one serve each other's
+// operations.
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/ravilushqa/boilerplate/internal/listing"
+	"github.com/ravilushqa/boilerplate/internal/recovery"
+)
+
+var (
+	runningOperations = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "operations_running",
+		Help: "Number of long-running operations running in this process.",
+	})
+	completedOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "operations_completed_total",
+		Help: "Number of long-running operations completed by status code.",
+	}, []string{"code"})
+)
+
+func init() {
+	prometheus.MustRegister(runningOperations, completedOperations)
+}
+
+// ErrClosed is returned by Start once the Manager is shutting down.
+var ErrClosed = errors.New("operations: shutting down")
+
+var (
+	errCanceled = errors.New("operation canceled")
+	errShutdown = errors.New("server shut down before the operation completed")
+)
+
+const (
+	// maxWait bounds WaitOperation, clients wait longer by calling it again.
+	maxWait = time.Minute
+	// pollInterval is how often WaitOperation reads operations running in
+	// other processes.
+	pollInterval = time.Second
+	// storeTimeout bounds storing the result of an operation, which happens
+	// after its context is canceled.
+	storeTimeout = 5 * time.Second
+)
+
+// listSpec describes operations to ListOperations: filters and order_by may
+// use name and done. Names are time ordered, so the newest come first.
+var listSpec = listing.Spec{
+	Fields:       listing.Fields{"name": listing.String, "done": listing.Bool},
+	DefaultOrder: "name desc",
+}
+
+// Task is the work of an operation. It reports progress by passing the new
+// metadata of the operation to update and returns the response of the
+// operation, nil for google.protobuf.Empty. Errors carrying a gRPC status
+// are returned to the client as they are, others are logged and returned
+// as INTERNAL.
+type Task func(ctx context.Context, update func(metadata proto.Message) error) (proto.Message, error)
+
+// Option configures the Manager.
+type Option interface {
+	apply(*Manager)
+}
+
+type optionFunc func(*Manager)
+
+func (f optionFunc) apply(m *Manager) { f(m) }
+
+// WithPageTokens signs the page tokens of ListOperations with tokens.
+// Without it they are signed with a key of the process.
+func WithPageTokens(tokens *listing.Tokens) Option {
+	return optionFunc(func(m *Manager) {
+		m.lister = listing.NewLister(listSpec, tokens)
+	})
+}
+
+// WithRecovery sets the handler of panicking tasks.
+func WithRecovery(h *recovery.Handler) Option {
+	return optionFunc(func(m *Manager) {
+		m.recovery = h
+	})
+}
+
+// running is an operation whose task runs in this process.
+type running struct {
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+}
+
+// Manager starts operations and serves google.longrunning.Operations. It
+// runs as a lifecycle component: once stopped, new operations are refused,
+// the running tasks are canceled and their operations end UNAVAILABLE.
+type Manager struct {
+	longrunningpb.UnimplementedOperationsServer
+
+	l        *slog.Logger
+	store    Store
+	lister   *listing.Lister
+	recovery *recovery.Handler
+
+	// ctx is the parent of the task contexts, canceled on shutdown
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	mu      sync.Mutex
+	closed  bool
+	running map[string]*running
+	wg      sync.WaitGroup
+}
+
+// NewManager returns a Manager keeping operations in store.
+func NewManager(l *slog.Logger, store Store, opts ...Option) *Manager {
+	m := &Manager{l: l, store: store, running: map[string]*running{}}
+	m.ctx, m.cancel = context.WithCancelCause(context.Background())
+	for _, o := range opts {
+		o.apply(m)
+	}
+	if m.lister == nil {
+		m.lister = listing.NewLister(listSpec, listing.NewTokens(nil, 0))
+	}
+	if m.recovery == nil {
+		m.recovery = recovery.New(l, nil)
+	}
+	return m
+}
+
+// Run blocks until ctx is done, then cancels the running tasks and waits
+// for them to store their result.
+func (m *Manager) Run(ctx context.Context) error {
+	<-ctx.Done()
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	m.cancel(errShutdown)
+	m.wg.Wait()
+	return nil
+}
+
+// Start creates an operation whose response task computes in the
+// background and returns it, for the handler to return to the client.
+// metadata is the initial metadata of the operation, it may be nil. task
+// runs detached from ctx, until it returns, the operation is canceled or
+// the Manager shuts down.
+func (m *Manager) Start(ctx context.Context, metadata proto.Message, task Task) (*longrunningpb.Operation, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+	op := &longrunningpb.Operation{Name: "operations/" + id.String()}
+	if metadata != nil {
+		if op.Metadata, err = anypb.New(metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrClosed
+	}
+	taskCtx, cancel := context.WithCancelCause(m.ctx)
+	r := &running{cancel: cancel, done: make(chan struct{})}
+	m.running[op.Name] = r
+	m.wg.Add(1)
+	m.mu.Unlock()
+
+	if err = m.store.Create(ctx, op); err != nil {
+		m.finish(op.Name, r)
+		return nil, fmt.Errorf("create operation: %w", err)
+	}
+	runningOperations.Inc()
+	go m.run(taskCtx, proto.Clone(op).(*longrunningpb.Operation), r, task)
+	return op, nil
+}
+
+func (m *Manager) run(ctx context.Context, op *longrunningpb.Operation, r *running, task Task) {
+	defer runningOperations.Dec()
+	defer m.finish(op.Name, r)
+
+	var mu sync.Mutex
+	update := func(metadata proto.Message) error {
+		a, err := anypb.New(metadata)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		op.Metadata = a
+		return m.store.Update(ctx, proto.Clone(op).(*longrunningpb.Operation))
+	}
+	resp, err := m.call(ctx, op.Name, task, update)
+
+	mu.Lock()
+	defer mu.Unlock()
+	op.Done = true
+	if err == nil {
+		op.Result = &longrunningpb.Operation_Response{Response: resp}
+	} else {
+		op.Result = &longrunningpb.Operation_Error{Error: m.toStatus(ctx, op.Name, err).Proto()}
+	}
+	completedOperations.WithLabelValues(codes.Code(op.GetError().GetCode()).String()).Inc()
+
+	// ctx is canceled when the operation was, the result is stored anyway
+	storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), storeTimeout)
+	defer cancel()
+	// a deleted operation has no client left to tell
+	if err = m.store.Update(storeCtx, op); err != nil && !errors.Is(err, ErrNotFound) {
+		m.l.Error("[OPERATIONS] failed to store the result", slog.String("operation", op.Name), slog.Any("error", err))
+	}
+}
+
+// call runs task and packs its response, a panic becomes an INTERNAL
+// status carrying the correlation ID it was logged under.
+func (m *Manager) call(ctx context.Context, name string, task Task, update func(proto.Message) error) (resp *anypb.Any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			id := m.recovery.Handle(ctx, p, "operations", map[string]string{"operation": name})
+			err = status.Error(codes.Internal, "internal error, correlation id "+id)
+		}
+	}()
+	msg, err := task(ctx, update)
+	if err != nil {
+		return nil, err
+	}
+	if msg == nil {
+		msg = &emptypb.Empty{}
+	}
+	return anypb.New(msg)
+}
+
+// toStatus returns the status of an operation that failed with err.
+func (m *Manager) toStatus(ctx context.Context, name string, err error) *status.Status {
+	if ctx.Err() != nil {
+		switch cause := context.Cause(ctx); {
+		case errors.Is(cause, errCanceled):
+			return status.New(codes.Canceled, cause.Error())
+		case errors.Is(cause, errShutdown):
+			return status.New(codes.Unavailable, cause.Error())
+		}
+	}
+	if s, ok := status.FromError(err); ok {
+		return s
+	}
+	m.l.Error("[OPERATIONS] operation failed", slog.String("operation", name), slog.Any("error", err))
+	return status.New(codes.Internal, codes.Internal.String())
+}
+
+func (m *Manager) finish(name string, r *running) {
+	m.mu.Lock()
+	delete(m.running, name)
+	m.mu.Unlock()
+	r.cancel(nil)
+	close(r.done)
+	m.wg.Done()
+}
@@ -0,0 +1,191 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/ravilushqa/boilerplate/internal/listing"
+)
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(slog.Default(), NewMemoryStore(time.Hour))
+
+	release := make(chan struct{})
+	updated := make(chan struct{})
+	op, err := m.Start(ctx, wrapperspb.String("queued"), func(ctx context.Context, update func(proto.Message) error) (proto.Message, error) {
+		require.NoError(t, update(wrapperspb.String("halfway")))
+		close(updated)
+		<-release
+		return wrapperspb.String("result"), nil
+	})
+	require.NoError(t, err)
+	require.Contains(t, op.Metadata.String(), "queued")
+
+	<-updated
+	got, err := m.GetOperation(ctx, &longrunningpb.GetOperationRequest{Name: op.Name})
+	require.NoError(t, err)
+	require.False(t, got.Done)
+	metadata, err := got.Metadata.UnmarshalNew()
+	require.NoError(t, err)
+	require.Equal(t, "halfway", metadata.(*wrapperspb.StringValue).Value)
+
+	got, err = m.WaitOperation(ctx, &longrunningpb.WaitOperationRequest{Name: op.Name, Timeout: durationpb.New(time.Millisecond)})
+	require.NoError(t, err)
+	require.False(t, got.Done, "timed out")
+
+	close(release)
+	got, err = m.WaitOperation(ctx, &longrunningpb.WaitOperationRequest{Name: op.Name})
+	require.NoError(t, err)
+	require.True(t, got.Done)
+	resp, err := got.GetResponse().UnmarshalNew()
+	require.NoError(t, err)
+	require.Equal(t, "result", resp.(*wrapperspb.StringValue).Value)
+
+	_, err = m.DeleteOperation(ctx, &longrunningpb.DeleteOperationRequest{Name: op.Name})
+	require.NoError(t, err)
+	_, err = m.GetOperation(ctx, &longrunningpb.GetOperationRequest{Name: op.Name})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestManager_errors(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(slog.Default(), NewMemoryStore(time.Hour))
+
+	for name, tc := range map[string]struct {
+		task Task
+		code codes.Code
+		msg  string
+	}{
+		"status": {
+			task: func(context.Context, func(proto.Message) error) (proto.Message, error) {
+				return nil, status.Error(codes.FailedPrecondition, "not ready")
+			},
+			code: codes.FailedPrecondition,
+			msg:  "not ready",
+		},
+		"internal": {
+			task: func(context.Context, func(proto.Message) error) (proto.Message, error) {
+				return nil, errors.New("database is down")
+			},
+			code: codes.Internal,
+			msg:  "Internal",
+		},
+		"panic": {
+			task: func(context.Context, func(proto.Message) error) (proto.Message, error) {
+				panic("boom")
+			},
+			code: codes.Internal,
+			msg:  "internal error, correlation id ",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			op, err := m.Start(ctx, nil, tc.task)
+			require.NoError(t, err)
+			got, err := m.WaitOperation(ctx, &longrunningpb.WaitOperationRequest{Name: op.Name})
+			require.NoError(t, err)
+			require.True(t, got.Done)
+			require.Equal(t, int32(tc.code), got.GetError().GetCode())
+			require.Contains(t, got.GetError().GetMessage(), tc.msg)
+		})
+	}
+}
+
+func TestManager_cancel(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(slog.Default(), NewMemoryStore(time.Hour))
+	task := func(ctx context.Context, _ func(proto.Message) error) (proto.Message, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	op, err := m.Start(ctx, nil, task)
+	require.NoError(t, err)
+	_, err = m.CancelOperation(ctx, &longrunningpb.CancelOperationRequest{Name: op.Name})
+	require.NoError(t, err)
+	got, err := m.WaitOperation(ctx, &longrunningpb.WaitOperationRequest{Name: op.Name})
+	require.NoError(t, err)
+	require.Equal(t, int32(codes.Canceled), got.GetError().GetCode())
+	_, err = m.CancelOperation(ctx, &longrunningpb.CancelOperationRequest{Name: op.Name})
+	require.NoError(t, err, "done operations are left as they are")
+
+	// shutdown cancels the running tasks and refuses new ones
+	op, err = m.Start(ctx, nil, task)
+	require.NoError(t, err)
+	runCtx, stop := context.WithCancel(ctx)
+	stopped := make(chan error)
+	go func() { stopped <- m.Run(runCtx) }()
+	stop()
+	require.NoError(t, <-stopped)
+	got, err = m.GetOperation(ctx, &longrunningpb.GetOperationRequest{Name: op.Name})
+	require.NoError(t, err)
+	require.Equal(t, int32(codes.Unavailable), got.GetError().GetCode())
+	_, err = m.Start(ctx, nil, task)
+	require.ErrorIs(t, err, ErrClosed)
+}
+
+func TestManager_ListOperations(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(slog.Default(), NewMemoryStore(time.Hour), WithPageTokens(listing.NewTokens([]byte("secret"), 0)))
+	release := make(chan struct{})
+	defer close(release)
+
+	var names []string
+	for i := range 3 {
+		op, err := m.Start(ctx, nil, func(context.Context, func(proto.Message) error) (proto.Message, error) {
+			if i == 0 {
+				<-release
+			}
+			return nil, nil
+		})
+		require.NoError(t, err)
+		names = append(names, op.Name)
+	}
+	for _, name := range names[1:] {
+		_, err := m.WaitOperation(ctx, &longrunningpb.WaitOperationRequest{Name: name})
+		require.NoError(t, err)
+	}
+
+	resp, err := m.ListOperations(ctx, &longrunningpb.ListOperationsRequest{Name: "operations", PageSize: 1, Filter: "done = true"})
+	require.NoError(t, err)
+	require.Len(t, resp.Operations, 1)
+	require.Equal(t, names[2], resp.Operations[0].Name, "newest first")
+	resp, err = m.ListOperations(ctx, &longrunningpb.ListOperationsRequest{PageSize: 1, Filter: "done = true", PageToken: resp.NextPageToken})
+	require.NoError(t, err)
+	require.Len(t, resp.Operations, 1)
+	require.Equal(t, names[1], resp.Operations[0].Name)
+	require.Empty(t, resp.NextPageToken)
+
+	_, err = m.ListOperations(ctx, &longrunningpb.ListOperationsRequest{Filter: "size > 3"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = m.ListOperations(ctx, &longrunningpb.ListOperationsRequest{Name: "jobs"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestMemoryStore_ttl(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(time.Minute)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.Create(ctx, &longrunningpb.Operation{Name: "operations/a"}))
+	now = now.Add(time.Hour)
+	_, err := s.Get(ctx, "operations/a")
+	require.NoError(t, err, "running operations do not expire")
+
+	require.NoError(t, s.Update(ctx, &longrunningpb.Operation{Name: "operations/a", Done: true}))
+	now = now.Add(2 * time.Minute)
+	_, err = s.Get(ctx, "operations/a")
+	require.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,132 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/ravilushqa/boilerplate/internal/listing"
+)
+
+// collection is the parent of the operations, the name ListOperations
+// accepts besides an empty one.
+const collection = "operations"
+
+// ListOperations lists the operations with the listing conventions: filter
+// and order_by on name and done, newest first by default.
+func (m *Manager) ListOperations(ctx context.Context, r *longrunningpb.ListOperationsRequest) (*longrunningpb.ListOperationsResponse, error) {
+	if r.Name != "" && r.Name != collection {
+		return nil, status.Errorf(codes.InvalidArgument, "name must be %q", collection)
+	}
+	q, err := m.lister.Parse(listing.Request{PageSize: int(r.PageSize), PageToken: r.PageToken, Filter: r.Filter})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	ops, next, err := m.store.List(ctx, q)
+	if err != nil {
+		return nil, m.storeError(collection, err)
+	}
+	resp := &longrunningpb.ListOperationsResponse{Operations: ops}
+	if next != nil {
+		resp.NextPageToken = m.lister.NextPageToken(q, *next)
+	}
+	return resp, nil
+}
+
+// GetOperation returns the latest state of an operation.
+func (m *Manager) GetOperation(ctx context.Context, r *longrunningpb.GetOperationRequest) (*longrunningpb.Operation, error) {
+	op, err := m.store.Get(ctx, r.Name)
+	if err != nil {
+		return nil, m.storeError(r.Name, err)
+	}
+	return op, nil
+}
+
+// DeleteOperation forgets an operation, a running task is not canceled.
+func (m *Manager) DeleteOperation(ctx context.Context, r *longrunningpb.DeleteOperationRequest) (*emptypb.Empty, error) {
+	if err := m.store.Delete(ctx, r.Name); err != nil {
+		return nil, m.storeError(r.Name, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// CancelOperation cancels the context of the task, which ends the
+// operation CANCELLED unless it completes first. Done operations are left
+// as they are. Only the process running the task can cancel it, others
+// answer UNAVAILABLE so the client retries.
+func (m *Manager) CancelOperation(ctx context.Context, r *longrunningpb.CancelOperationRequest) (*emptypb.Empty, error) {
+	op, err := m.store.Get(ctx, r.Name)
+	if err != nil {
+		return nil, m.storeError(r.Name, err)
+	}
+	if op.Done {
+		return &emptypb.Empty{}, nil
+	}
+	m.mu.Lock()
+	run, ok := m.running[r.Name]
+	m.mu.Unlock()
+	if !ok {
+		return nil, status.Error(codes.Unavailable, "operation is running in another instance")
+	}
+	run.cancel(errCanceled)
+	return &emptypb.Empty{}, nil
+}
+
+// WaitOperation returns the operation once it is done, or its latest state
+// after r.Timeout, at most a minute, or once the server shuts down.
+func (m *Manager) WaitOperation(ctx context.Context, r *longrunningpb.WaitOperationRequest) (*longrunningpb.Operation, error) {
+	timeout := maxWait
+	if d := r.GetTimeout().AsDuration(); d > 0 && d < maxWait {
+		timeout = d
+	}
+	wait, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+
+	for {
+		// tasks of this process signal their end, others are polled; the
+		// task is looked up first, so an end in between is not missed
+		var done chan struct{}
+		m.mu.Lock()
+		if run, ok := m.running[r.Name]; ok {
+			done = run.done
+		}
+		m.mu.Unlock()
+		op, err := m.store.Get(ctx, r.Name)
+		if err != nil {
+			return nil, m.storeError(r.Name, err)
+		}
+		if op.Done {
+			return op, nil
+		}
+
+		select {
+		case <-done:
+		case <-poll.C:
+		case <-m.ctx.Done():
+			return op, nil
+		case <-wait.Done():
+			if ctx.Err() != nil {
+				return nil, status.FromContextError(ctx.Err()).Err()
+			}
+			return op, nil
+		}
+	}
+}
+
+// storeError maps an error of the store to a status. Messages of internal
+// errors are logged and not exposed to the client.
+func (m *Manager) storeError(name string, err error) error {
+	if errors.Is(err, ErrNotFound) {
+		return status.Errorf(codes.NotFound, "operation %q not found", name)
+	}
+	m.l.Error("[OPERATIONS] store failed", slog.String("operation", name), slog.Any("error", err))
+	return status.Error(codes.Internal, codes.Internal.String())
+}
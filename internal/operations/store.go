@@ -0,0 +1,145 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/ravilushqa/boilerplate/internal/listing"
+)
+
+// ErrNotFound is returned by a Store for an unknown operation.
+var ErrNotFound = errors.New("operations: not found")
+
+// Store keeps operations by name. The Manager is the only writer of an
+// operation while its task runs. Callers may modify the messages passed to
+// and returned by a Store, so it keeps copies.
+type Store interface {
+	// Create stores a new operation.
+	Create(ctx context.Context, op *longrunningpb.Operation) error
+	// Get returns the operation named name or ErrNotFound.
+	Get(ctx context.Context, name string) (*longrunningpb.Operation, error)
+	// List returns the page of operations selected by q, with the position
+	// of its last operation when more follow.
+	List(ctx context.Context, q *listing.Query) ([]*longrunningpb.Operation, *listing.Cursor, error)
+	// Update replaces the operation of the same name, or returns
+	// ErrNotFound when it was deleted.
+	Update(ctx context.Context, op *longrunningpb.Operation) error
+	// Delete removes the operation named name or returns ErrNotFound.
+	Delete(ctx context.Context, name string) error
+}
+
+type record struct {
+	op *longrunningpb.Operation
+	// expires is set once the operation is done
+	expires time.Time
+}
+
+// MemoryStore is a Store local to the process. Replicas do not share it, so
+// clients only find operations on the replica that started them, and they
+// are lost on restart.
+type MemoryStore struct {
+	ttl       time.Duration
+	now       func() time.Time
+	mu        sync.Mutex
+	records   map[string]*record
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns a store keeping done operations for ttl.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{ttl: ttl, now: time.Now, records: map[string]*record{}}
+}
+
+func (s *MemoryStore) Create(_ context.Context, op *longrunningpb.Operation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(s.now())
+	s.records[op.Name] = &record{op: proto.Clone(op).(*longrunningpb.Operation)}
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, name string) (*longrunningpb.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[name]
+	if !ok || s.expired(r, s.now()) {
+		return nil, ErrNotFound
+	}
+	return proto.Clone(r.op).(*longrunningpb.Operation), nil
+}
+
+func (s *MemoryStore) List(_ context.Context, q *listing.Query) ([]*longrunningpb.Operation, *listing.Cursor, error) {
+	s.mu.Lock()
+	now := s.now()
+	ops := make([]*longrunningpb.Operation, 0, len(s.records))
+	for _, r := range s.records {
+		if !s.expired(r, now) {
+			ops = append(ops, r.op)
+		}
+	}
+	s.mu.Unlock()
+
+	page, next := listing.Page(ops, q, field, func(op *longrunningpb.Operation) string { return op.Name })
+	for i, op := range page {
+		page[i] = proto.Clone(op).(*longrunningpb.Operation)
+	}
+	return page, next, nil
+}
+
+func (s *MemoryStore) Update(_ context.Context, op *longrunningpb.Operation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[op.Name]
+	if !ok {
+		return ErrNotFound
+	}
+	r.op = proto.Clone(op).(*longrunningpb.Operation)
+	if op.Done {
+		r.expires = s.now().Add(s.ttl)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[name]
+	if !ok || s.expired(r, s.now()) {
+		return ErrNotFound
+	}
+	delete(s.records, name)
+	return nil
+}
+
+func (s *MemoryStore) expired(r *record, now time.Time) bool {
+	return !r.expires.IsZero() && !now.Before(r.expires)
+}
+
+// sweep drops expired operations, at most once a minute.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for name, r := range s.records {
+		if s.expired(r, now) {
+			delete(s.records, name)
+		}
+	}
+}
+
+// field returns the value of a listSpec field of op.
+func field(op *longrunningpb.Operation, name string) any {
+	switch name {
+	case "name":
+		return op.Name
+	case "done":
+		return op.Done
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ravilushqa/boilerplate/internal/messaging"
+)
+
+// MemoryStore is a Store local to the process, without transactions. It
+// is meant for tests and for services without storage.
+type MemoryStore struct {
+	mu     sync.Mutex
+	lastID int64
+	events []*Event
+	now    func() time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{now: time.Now}
+}
+
+// Enqueue adds msgs to the outbox.
+func (s *MemoryStore) Enqueue(_ context.Context, msgs ...*messaging.Message) error {
+	events, err := prepare(msgs, s.now())
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range events {
+		s.lastID++
+		e.ID = s.lastID
+		s.events = append(s.events, e)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Pending(_ context.Context, now time.Time, limit int) ([]*Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	type key struct{ topic, key string }
+	waiting := map[key]bool{}
+	var events []*Event
+	for _, e := range s.events {
+		k := key{e.Message.Topic, e.Message.Key}
+		if e.NextAttempt.After(now) {
+			waiting[k] = e.Message.Key != ""
+			continue
+		}
+		if waiting[k] {
+			continue
+		}
+		c := *e
+		events = append(events, &c)
+		if len(events) == limit {
+			break
+		}
+	}
+	return events, nil
+}
+
+func (s *MemoryStore) Published(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.events {
+		if e.ID == id {
+			s.events = append(s.events[:i], s.events[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStore) Failed(_ context.Context, id int64, next time.Time, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.events {
+		if e.ID == id {
+			e.Attempts++
+			e.NextAttempt = next
+			return nil
+		}
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,68 @@
+// Package outbox publishes events reliably along with the state changes
+// they describe. A handler enqueues its events in the transaction that
+// writes its state, so both are committed or neither is, and the Relay
+// publishes the committed events afterwards. Publishing is at least once:
+// an event may be published again when the relay stops between publishing
+// it and marking it published, so consumers deduplicate by message ID.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ravilushqa/boilerplate/internal/messaging"
+)
+
+// ErrNotFound is returned by a Store for an event no longer in the outbox.
+var ErrNotFound = errors.New("outbox: event not found")
+
+// Event is a message waiting in the outbox.
+type Event struct {
+	// ID orders the events of the outbox.
+	ID      int64
+	Message *messaging.Message
+	// Attempts counts the failed attempts to publish the event.
+	Attempts int
+	// NextAttempt is when the event is due.
+	NextAttempt time.Time
+	CreateTime  time.Time
+}
+
+// Store keeps the outbox. Events are added by the Enqueue method of the
+// implementation, which takes the transaction of the handler when the
+// store has transactions.
+type Store interface {
+	// Pending returns up to limit events due at now, oldest first. Events
+	// following a not yet due event of the same topic and key are left out,
+	// so that the events of a key are published in order.
+	Pending(ctx context.Context, now time.Time, limit int) ([]*Event, error)
+	// Published removes the event with id from the outbox.
+	Published(ctx context.Context, id int64) error
+	// Failed records a failed attempt to publish the event with id, which
+	// is due again at next.
+	Failed(ctx context.Context, id int64, next time.Time, reason string) error
+}
+
+// prepare returns the events of msgs, setting their missing IDs and
+// times.
+func prepare(msgs []*messaging.Message, now time.Time) ([]*Event, error) {
+	events := make([]*Event, len(msgs))
+	for i, msg := range msgs {
+		m := *msg
+		if m.ID == "" {
+			id, err := uuid.NewV7()
+			if err != nil {
+				return nil, err
+			}
+			m.ID = id.String()
+		}
+		if m.Time.IsZero() {
+			m.Time = now
+		}
+		events[i] = &Event{Message: &m, NextAttempt: now, CreateTime: now}
+	}
+	return events, nil
+}
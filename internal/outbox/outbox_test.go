@@ -0,0 +1,184 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ravilushqa/boilerplate/internal/messaging"
+	"github.com/ravilushqa/boilerplate/internal/storage"
+)
+
+// stores returns the Store implementations with a function enqueuing msgs,
+// committed when commit is true.
+func stores(t *testing.T) map[string]struct {
+	store   Store
+	enqueue func(commit bool, msgs ...*messaging.Message)
+} {
+	mem := NewMemoryStore()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "outbox.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	m, err := storage.NewMigrator(slog.Default(), db, storage.Migrations())
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+	sqlStore := NewSQLStore(db)
+
+	return map[string]struct {
+		store   Store
+		enqueue func(commit bool, msgs ...*messaging.Message)
+	}{
+		"memory": {mem, func(commit bool, msgs ...*messaging.Message) {
+			if commit {
+				require.NoError(t, mem.Enqueue(context.Background(), msgs...))
+			}
+		}},
+		"sql": {sqlStore, func(commit bool, msgs ...*messaging.Message) {
+			tx, err := db.Begin()
+			require.NoError(t, err)
+			require.NoError(t, sqlStore.Enqueue(context.Background(), tx, msgs...))
+			if commit {
+				require.NoError(t, tx.Commit())
+			} else {
+				require.NoError(t, tx.Rollback())
+			}
+		}},
+	}
+}
+
+func payloads(events []*Event) []string {
+	var got []string
+	for _, e := range events {
+		got = append(got, string(e.Message.Payload))
+	}
+	return got
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	for name, tc := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			tc.enqueue(false, &messaging.Message{Topic: "orders", Payload: []byte("rolled back")})
+			tc.enqueue(true,
+				&messaging.Message{Topic: "orders", Key: "1", Payload: []byte("a"), Headers: map[string]string{"tenant": "t1"}},
+				&messaging.Message{Topic: "orders", Key: "2", Payload: []byte("b")},
+				&messaging.Message{Topic: "orders", Key: "1", Payload: []byte("c")},
+				&messaging.Message{Topic: "orders", Payload: []byte("d")},
+			)
+
+			now := time.Now().Add(time.Second)
+			events, err := tc.store.Pending(ctx, now, 10)
+			require.NoError(t, err)
+			require.Equal(t, []string{"a", "b", "c", "d"}, payloads(events))
+			a := events[0]
+			require.NotEmpty(t, a.Message.ID)
+			require.Equal(t, "t1", a.Message.Headers["tenant"])
+			require.WithinDuration(t, time.Now(), a.CreateTime, time.Minute)
+
+			// c waits for a, which is not due
+			require.NoError(t, tc.store.Failed(ctx, a.ID, now.Add(time.Minute), "unavailable"))
+			events, err = tc.store.Pending(ctx, now, 10)
+			require.NoError(t, err)
+			require.Equal(t, []string{"b", "d"}, payloads(events))
+			events, err = tc.store.Pending(ctx, now, 1)
+			require.NoError(t, err)
+			require.Equal(t, []string{"b"}, payloads(events))
+
+			events, err = tc.store.Pending(ctx, now.Add(time.Minute), 10)
+			require.NoError(t, err)
+			require.Equal(t, []string{"a", "b", "c", "d"}, payloads(events))
+			require.Equal(t, 1, events[0].Attempts)
+
+			for _, e := range events {
+				require.NoError(t, tc.store.Published(ctx, e.ID))
+			}
+			require.ErrorIs(t, tc.store.Published(ctx, a.ID), ErrNotFound)
+			require.ErrorIs(t, tc.store.Failed(ctx, a.ID, now, ""), ErrNotFound)
+			events, err = tc.store.Pending(ctx, now.Add(time.Hour), 10)
+			require.NoError(t, err)
+			require.Empty(t, events)
+		})
+	}
+}
+
+// publisher fails the messages of the keys in failing.
+type publisher struct {
+	mu        sync.Mutex
+	failing   map[string]bool
+	published []string
+}
+
+func (p *publisher) Publish(_ context.Context, msg *messaging.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failing[msg.Key] {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, string(msg.Payload))
+	return nil
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	pub := &publisher{failing: map[string]bool{"1": true}}
+	r := NewRelay(slog.Default(), store, pub, RelayConfig{BatchSize: 2, Backoff: time.Second})
+	require.NoError(t, store.Enqueue(ctx,
+		&messaging.Message{Topic: "orders", Key: "1", Payload: []byte("a")},
+		&messaging.Message{Topic: "orders", Key: "2", Payload: []byte("b")},
+		&messaging.Message{Topic: "orders", Key: "1", Payload: []byte("c")},
+		&messaging.Message{Topic: "orders", Key: "2", Payload: []byte("d")},
+		&messaging.Message{Topic: "orders", Payload: []byte("e")},
+	))
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	require.NoError(t, r.relay(ctx))
+	require.Equal(t, []string{"b", "d", "e"}, pub.published, "key 1 is held back")
+
+	// retried after the backoff, doubled on each failure
+	now = now.Add(time.Second)
+	require.NoError(t, r.relay(ctx))
+	events, err := store.Pending(ctx, now.Add(2*time.Second), 10)
+	require.NoError(t, err)
+	require.Equal(t, 2, events[0].Attempts)
+
+	pub.failing = nil
+	now = now.Add(2 * time.Second)
+	require.NoError(t, r.relay(ctx))
+	require.Equal(t, []string{"b", "d", "e", "a", "c"}, pub.published)
+}
+
+func TestRelay_Run(t *testing.T) {
+	store := NewMemoryStore()
+	b := messaging.NewMemoryBroker()
+	r := NewRelay(slog.Default(), store, b, RelayConfig{Interval: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- r.Run(ctx) }()
+
+	received := make(chan *messaging.Message, 1)
+	consumer := messaging.NewConsumer(slog.Default(), b, "orders", "billing", func(_ context.Context, msg *messaging.Message) error {
+		received <- msg
+		return nil
+	})
+	go func() { _ = consumer.Run(ctx) }()
+
+	msg := &messaging.Message{Topic: "orders", Key: "1", Payload: []byte("a")}
+	require.NoError(t, store.Enqueue(ctx, msg))
+	r.Notify()
+	got := <-received
+	require.Equal(t, "a", string(got.Payload))
+	require.NotEmpty(t, got.ID)
+
+	cancel()
+	require.NoError(t, <-stopped)
+}
@@ -0,0 +1,202 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ravilushqa/boilerplate/internal/jobs"
+	"github.com/ravilushqa/boilerplate/internal/messaging"
+)
+
+var (
+	publishedEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_published_total",
+		Help: "Number of outbox events published by topic.",
+	}, []string{"topic"})
+	failedEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_publish_failures_total",
+		Help: "Number of failed attempts to publish an outbox event by topic.",
+	}, []string{"topic"})
+	eventLag = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "outbox_lag_seconds",
+		Help:    "Time from enqueuing an outbox event to publishing it.",
+		Buckets: []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300, 900, 3600},
+	})
+)
+
+func init() {
+	prometheus.MustRegister(publishedEvents, failedEvents, eventLag)
+}
+
+// RelayConfig configures a Relay.
+type RelayConfig struct {
+	// Interval is how often the outbox is polled. 1s by default.
+	Interval time.Duration
+	// BatchSize is the number of events read at once. 100 by default.
+	BatchSize int
+	// Backoff is the wait before publishing a failed event again, doubled
+	// after each further failure up to MaxBackoff. 1s and 5m by default.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Option configures a Relay.
+type Option interface {
+	apply(*Relay)
+}
+
+type optionFunc func(*Relay)
+
+func (f optionFunc) apply(r *Relay) { f(r) }
+
+// WithLocker has the Relay take a lock of locker while it relays, so that
+// one replica at a time publishes and events are not published twice.
+func WithLocker(locker jobs.Locker) Option {
+	return optionFunc(func(r *Relay) {
+		r.locker = locker
+	})
+}
+
+// lockKey is the lock of the Relay.
+const lockKey = "outbox/relay"
+
+// Relay publishes the events of the outbox. It runs as a lifecycle
+// component: once stopped, the event being published is finished and no
+// other one is started. Events failing to publish are retried with backoff
+// until they are published, holding back the following events of their
+// key.
+type Relay struct {
+	l      *slog.Logger
+	store  Store
+	pub    messaging.Publisher
+	cfg    RelayConfig
+	locker jobs.Locker
+	now    func() time.Time
+	wake   chan struct{}
+}
+
+// NewRelay returns a Relay publishing the events of store to pub.
+func NewRelay(l *slog.Logger, store Store, pub messaging.Publisher, cfg RelayConfig, opts ...Option) *Relay {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 5 * time.Minute
+	}
+	r := &Relay{l: l, store: store, pub: pub, cfg: cfg, now: time.Now, wake: make(chan struct{}, 1)}
+	for _, o := range opts {
+		o.apply(r)
+	}
+	return r
+}
+
+// Notify has the Relay poll the outbox now rather than at the next
+// interval. Handlers call it after committing events, for them to be
+// published without waiting.
+func (r *Relay) Notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run polls the outbox until ctx is done.
+func (r *Relay) Run(ctx context.Context) error {
+	t := time.NewTicker(r.cfg.Interval)
+	defer t.Stop()
+	for {
+		if err := r.relay(ctx); err != nil {
+			r.l.Error("[OUTBOX] failed to relay events", slog.Any("error", err))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// relay publishes the due events until none is left or ctx is done.
+func (r *Relay) relay(ctx context.Context) error {
+	// an event being published is finished on shutdown
+	work := context.WithoutCancel(ctx)
+	if r.locker != nil {
+		unlock, ok, err := r.locker.TryLock(work, lockKey)
+		if err != nil || !ok {
+			return err
+		}
+		defer unlock()
+	}
+
+	for ctx.Err() == nil {
+		events, err := r.store.Pending(work, r.now(), r.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		published, err := r.publish(ctx, events)
+		if err != nil {
+			return err
+		}
+		// the rest of the outbox waits for the failed events
+		if len(events) < r.cfg.BatchSize || published == 0 {
+			return nil
+		}
+	}
+	return nil
+}
+
+// publish publishes events in order, the events of a key failing are held
+// back with the following ones. It returns the number of published events.
+func (r *Relay) publish(ctx context.Context, events []*Event) (int, error) {
+	type key struct{ topic, key string }
+	failed := map[key]bool{}
+	published := 0
+	work := context.WithoutCancel(ctx)
+	for _, e := range events {
+		if ctx.Err() != nil {
+			break
+		}
+		k := key{e.Message.Topic, e.Message.Key}
+		if e.Message.Key != "" && failed[k] {
+			continue
+		}
+		if err := r.pub.Publish(work, e.Message); err != nil {
+			failed[k] = true
+			failedEvents.WithLabelValues(e.Message.Topic).Inc()
+			next := r.now().Add(r.backoff(e.Attempts + 1))
+			r.l.Warn("[OUTBOX] failed to publish event",
+				slog.String("topic", e.Message.Topic), slog.String("message_id", e.Message.ID),
+				slog.Int("attempts", e.Attempts+1), slog.Time("next_attempt", next), slog.Any("error", err))
+			if err = r.store.Failed(work, e.ID, next, err.Error()); err != nil {
+				return published, err
+			}
+			continue
+		}
+		if err := r.store.Published(work, e.ID); err != nil {
+			return published, err
+		}
+		published++
+		publishedEvents.WithLabelValues(e.Message.Topic).Inc()
+		eventLag.Observe(r.now().Sub(e.CreateTime).Seconds())
+	}
+	return published, nil
+}
+
+// backoff returns the wait after the attempts-th failure.
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.cfg.Backoff
+	for i := 1; i < attempts && d < r.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, r.cfg.MaxBackoff)
+}
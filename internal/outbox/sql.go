@@ -0,0 +1,122 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ravilushqa/boilerplate/internal/messaging"
+	"github.com/ravilushqa/boilerplate/internal/storage"
+)
+
+// SQLStore is a Store in the outbox table of a database, created by the
+// 0002_outbox migration of package storage. Times are Unix microseconds and
+// queries use ? placeholders, as SQLite and MySQL do.
+type SQLStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// NewSQLStore returns a SQLStore reading the outbox of db.
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db, now: time.Now}
+}
+
+// Enqueue adds msgs to the outbox within tx, the transaction of the
+// handler.
+func (s *SQLStore) Enqueue(ctx context.Context, tx storage.Execer, msgs ...*messaging.Message) error {
+	events, err := prepare(msgs, s.now())
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		m := e.Message
+		headers, err := json.Marshal(m.Headers)
+		if err != nil {
+			return fmt.Errorf("encode headers: %w", err)
+		}
+		if m.Payload == nil {
+			m.Payload = []byte{}
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO outbox (message_id, topic, message_key, headers, payload, message_time, create_time, next_attempt)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			m.ID, m.Topic, m.Key, string(headers), m.Payload, m.Time.UnixMicro(), e.CreateTime.UnixMicro(), e.NextAttempt.UnixMicro())
+		if err != nil {
+			return fmt.Errorf("insert event: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLStore) Pending(ctx context.Context, now time.Time, limit int) ([]*Event, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, message_id, topic, message_key, headers, payload, message_time, create_time, attempts, next_attempt
+		FROM outbox o
+		WHERE next_attempt <= ? AND NOT EXISTS (
+			SELECT 1 FROM outbox w
+			WHERE o.message_key <> '' AND w.topic = o.topic AND w.message_key = o.message_key
+				AND w.id < o.id AND w.next_attempt > ?
+		)
+		ORDER BY id
+		LIMIT ?`,
+		now.UnixMicro(), now.UnixMicro(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		var (
+			e                                    Event
+			m                                    messaging.Message
+			headers                              string
+			messageTime, createTime, nextAttempt int64
+		)
+		err = rows.Scan(&e.ID, &m.ID, &m.Topic, &m.Key, &headers, &m.Payload, &messageTime, &createTime, &e.Attempts, &nextAttempt)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(headers), &m.Headers); err != nil {
+			return nil, fmt.Errorf("decode headers of event %d: %w", e.ID, err)
+		}
+		m.Time = time.UnixMicro(messageTime)
+		e.Message, e.CreateTime, e.NextAttempt = &m, time.UnixMicro(createTime), time.UnixMicro(nextAttempt)
+		events = append(events, &e)
+	}
+	return events, rows.Err()
+}
+
+func (s *SQLStore) Published(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM outbox WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+func (s *SQLStore) Failed(ctx context.Context, id int64, next time.Time, reason string) error {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE outbox SET attempts = attempts + 1, next_attempt = ?, last_error = ?
+		WHERE id = ?`,
+		next.UnixMicro(), reason, id)
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+// affected returns ErrNotFound when res changed no row.
+func affected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Package recovery handles panics recovered by the HTTP middleware and the
+// gRPC interceptors the same way: the stack is logged with the request
+// context, counted, optionally forwarded to an error reporting service and
+// identified by a correlation ID that is returned to the client instead of
+// the panic value.
+package recovery
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var panicsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "panics_recovered_total",
+	Help: "Number of panics recovered while handling requests.",
+}, []string{"transport"})
+
+func init() {
+	prometheus.MustRegister(panicsTotal)
+}
+
+// Reporter forwards recovered panics to an error tracking service. A Sentry
+// adapter would call hub.CaptureException(err) with tags set on the scope.
+type Reporter interface {
+	Report(ctx context.Context, err error, tags map[string]string)
+}
+
+// Handler handles recovered panics.
+type Handler struct {
+	l        *slog.Logger
+	reporter Reporter
+}
+
+// New returns a Handler. reporter may be nil.
+func New(l *slog.Logger, reporter Reporter) *Handler {
+	return &Handler{l: l, reporter: reporter}
+}
+
+// Handle must be called from the deferred function that recovered p, so that
+// the logged stack includes the panicking frame. transport labels the panics
+// counter, tags describe the request. It returns the correlation ID.
+func (h *Handler) Handle(ctx context.Context, p interface{}, transport string, tags map[string]string) string {
+	id := newID()
+	stack := debug.Stack()
+	panicsTotal.WithLabelValues(transport).Inc()
+
+	attrs := []slog.Attr{
+		slog.String("correlation_id", id),
+		slog.String("transport", transport),
+		slog.Any("panic", p),
+		slog.String("stack", string(stack)),
+	}
+	for k, v := range tags {
+		attrs = append(attrs, slog.String(k, v))
+	}
+	h.l.LogAttrs(ctx, slog.LevelError, "panic recovered", attrs...)
+
+	if h.reporter != nil {
+		err, ok := p.(error)
+		if ok {
+			err = fmt.Errorf("panic: %w", err)
+		} else {
+			err = fmt.Errorf("panic: %v", p)
+		}
+		reportTags := map[string]string{"correlation_id": id, "transport": transport}
+		for k, v := range tags {
+			reportTags[k] = v
+		}
+		h.reporter.Report(ctx, err, reportTags)
+	}
+	return id
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
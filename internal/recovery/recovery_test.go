@@ -0,0 +1,43 @@
+package recovery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type reporter struct {
+	err  error
+	tags map[string]string
+}
+
+func (r *reporter) Report(_ context.Context, err error, tags map[string]string) {
+	r.err, r.tags = err, tags
+}
+
+func TestHandler_Handle(t *testing.T) {
+	buf := &bytes.Buffer{}
+	rep := &reporter{}
+	h := New(slog.New(slog.NewTextHandler(buf, nil)), rep)
+
+	boom := errors.New("boom")
+	var id string
+	func() {
+		defer func() {
+			id = h.Handle(context.Background(), recover(), "http", map[string]string{"path": "/greet"})
+		}()
+		panic(boom)
+	}()
+
+	require.Len(t, id, 32)
+	require.Contains(t, buf.String(), "correlation_id="+id)
+	require.Contains(t, buf.String(), "path=/greet")
+	require.Contains(t, buf.String(), "TestHandler_Handle", "stack includes the panicking frame")
+
+	require.ErrorIs(t, rep.err, boom)
+	require.Equal(t, map[string]string{"correlation_id": id, "transport": "http", "path": "/greet"}, rep.tags)
+}
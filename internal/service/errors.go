@@ -0,0 +1,25 @@
+package service
+
+import "errors"
+
+// Error kinds. Service errors wrap one of them so that transports can map
+// them to status codes with errors.Is without knowing every service error.
+var (
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+)
+
+type kindError struct {
+	kind error
+	msg  string
+}
+
+func (e *kindError) Error() string { return e.msg }
+
+func (e *kindError) Unwrap() error { return e.kind }
+
+// NewError returns an error with message msg that matches kind.
+func NewError(kind error, msg string) error {
+	return &kindError{kind: kind, msg: msg}
+}
@@ -0,0 +1,168 @@
+// Package service contains the business logic shared by the transports.
+// Transport adapters in internal/app only decode requests, call a service
+// and encode the result or map its error.
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ravilushqa/boilerplate/internal/listing"
+)
+
+var ErrNameRequired = NewError(ErrInvalidArgument, "name is required")
+
+// historySize is the number of recent greetings the greeter lists.
+const historySize = 1000
+
+// Greeting is a greeting the greeter sent.
+type Greeting struct {
+	ID         string
+	Name       string
+	Message    string
+	CreateTime time.Time
+}
+
+// GreetingSpec describes the greetings collection to list requests:
+// filters and order_by may use name, message and create_time.
+var GreetingSpec = listing.Spec{
+	Fields: listing.Fields{
+		"name":        listing.String,
+		"message":     listing.String,
+		"create_time": listing.Timestamp,
+	},
+	DefaultOrder: "create_time desc",
+}
+
+// Greeter greets people by name.
+type Greeter interface {
+	Greet(ctx context.Context, name string) (string, error)
+	// ListGreetings returns a page of the recent greetings and the token of
+	// the next page, empty on the last one.
+	ListGreetings(ctx context.Context, req listing.Request) ([]Greeting, string, error)
+}
+
+// GreetingRepository keeps the greetings sent.
+type GreetingRepository interface {
+	// Add stores g and returns it with its ID set.
+	Add(ctx context.Context, g Greeting) (Greeting, error)
+	// Recent returns up to n of the latest greetings, oldest first.
+	Recent(ctx context.Context, n int) ([]Greeting, error)
+}
+
+// GreeterOption configures the greeter.
+type GreeterOption interface {
+	apply(*greeter)
+}
+
+type greeterOptionFunc func(*greeter)
+
+func (f greeterOptionFunc) apply(g *greeter) { f(g) }
+
+// WithRepository keeps the greetings in r. Without it they are kept in
+// memory, up to the most recent historySize.
+func WithRepository(r GreetingRepository) GreeterOption {
+	return greeterOptionFunc(func(g *greeter) {
+		g.repo = r
+	})
+}
+
+// WithPageTokens signs the page tokens of ListGreetings with tokens.
+// Without it they are signed with a key of the process.
+func WithPageTokens(tokens *listing.Tokens) GreeterOption {
+	return greeterOptionFunc(func(g *greeter) {
+		g.lister = listing.NewLister(GreetingSpec, tokens)
+	})
+}
+
+type greeter struct {
+	lister *listing.Lister
+	repo   GreetingRepository
+}
+
+func NewGreeter(opts ...GreeterOption) Greeter {
+	g := &greeter{
+		lister: listing.NewLister(GreetingSpec, listing.NewTokens(nil, 0)),
+		repo:   &memoryGreetings{},
+	}
+	for _, o := range opts {
+		o.apply(g)
+	}
+	return g
+}
+
+func (g *greeter) Greet(ctx context.Context, name string) (string, error) {
+	if name == "" {
+		return "", ErrNameRequired
+	}
+	message := "Hello " + name
+
+	_, err := g.repo.Add(ctx, Greeting{
+		Name:       name,
+		Message:    message,
+		CreateTime: time.Now().UTC(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("add greeting: %w", err)
+	}
+	return message, nil
+}
+
+func (g *greeter) ListGreetings(ctx context.Context, req listing.Request) ([]Greeting, string, error) {
+	q, err := g.lister.Parse(req)
+	if err != nil {
+		return nil, "", NewError(ErrInvalidArgument, err.Error())
+	}
+
+	history, err := g.repo.Recent(ctx, historySize)
+	if err != nil {
+		return nil, "", fmt.Errorf("list greetings: %w", err)
+	}
+
+	page, next := listing.Page(history, q, greetingField, func(gr Greeting) string { return gr.ID })
+	if next == nil {
+		return page, "", nil
+	}
+	return page, g.lister.NextPageToken(q, *next), nil
+}
+
+func greetingField(gr Greeting, field string) any {
+	switch field {
+	case "name":
+		return gr.Name
+	case "message":
+		return gr.Message
+	case "create_time":
+		return gr.CreateTime
+	}
+	return nil
+}
+
+// memoryGreetings is the GreetingRepository of a greeter without one, it
+// keeps the most recent historySize greetings.
+type memoryGreetings struct {
+	mu      sync.Mutex
+	seq     int
+	history []Greeting
+}
+
+func (r *memoryGreetings) Add(_ context.Context, g Greeting) (Greeting, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	g.ID = strconv.Itoa(r.seq)
+	if len(r.history) == historySize {
+		r.history = append(r.history[:0], r.history[1:]...)
+	}
+	r.history = append(r.history, g)
+	return g, nil
+}
+
+func (r *memoryGreetings) Recent(_ context.Context, n int) ([]Greeting, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Greeting(nil), r.history[max(len(r.history)-n, 0):]...), nil
+}
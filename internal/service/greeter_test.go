@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ravilushqa/boilerplate/internal/listing"
+)
+
+func TestGreeter_Greet(t *testing.T) {
+	g := NewGreeter()
+
+	got, err := g.Greet(context.Background(), "World")
+	require.NoError(t, err)
+	require.Equal(t, "Hello World", got)
+
+	_, err = g.Greet(context.Background(), "")
+	require.ErrorIs(t, err, ErrNameRequired)
+	require.ErrorIs(t, err, ErrInvalidArgument)
+	require.False(t, errors.Is(err, ErrNotFound))
+	require.EqualError(t, err, "name is required")
+}
+
+func TestGreeter_ListGreetings(t *testing.T) {
+	ctx := context.Background()
+	g := NewGreeter(WithPageTokens(listing.NewTokens([]byte("secret"), 0)))
+	for _, name := range []string{"Alice", "Bob", "Carol"} {
+		_, err := g.Greet(ctx, name)
+		require.NoError(t, err)
+	}
+
+	page, next, err := g.ListGreetings(ctx, listing.Request{PageSize: 2, OrderBy: "name"})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	require.Equal(t, "Alice", page[0].Name)
+	require.Equal(t, "Hello Bob", page[1].Message)
+	require.NotEmpty(t, next)
+
+	page, next, err = g.ListGreetings(ctx, listing.Request{PageSize: 2, OrderBy: "name", PageToken: next})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	require.Equal(t, "Carol", page[0].Name)
+	require.Empty(t, next)
+
+	page, _, err = g.ListGreetings(ctx, listing.Request{Filter: `name = "B*" OR message : Carol`})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+
+	_, _, err = g.ListGreetings(ctx, listing.Request{Filter: "age > 3"})
+	require.ErrorIs(t, err, ErrInvalidArgument)
+	require.EqualError(t, err, `invalid filter: unknown field "age"`)
+}
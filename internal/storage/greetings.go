@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/ravilushqa/boilerplate/internal/messaging"
+	"github.com/ravilushqa/boilerplate/internal/service"
+)
+
+// GreetingsTopic is the topic of the event enqueued for each greeting
+// added, keyed by name. Its payload is the greeting as JSON: id, name,
+// message and create_time.
+const GreetingsTopic = "greetings"
+
+// Outbox enqueues events in the transaction writing the state they
+// describe, *outbox.SQLStore implements it.
+type Outbox interface {
+	Enqueue(ctx context.Context, tx Execer, msgs ...*messaging.Message) error
+}
+
+// Notifier is told about events once they are committed, *outbox.Relay
+// implements it.
+type Notifier interface {
+	Notify()
+}
+
+// GreetingsOption configures Greetings.
+type GreetingsOption interface {
+	apply(*Greetings)
+}
+
+type greetingsOptionFunc func(*Greetings)
+
+func (f greetingsOptionFunc) apply(r *Greetings) { f(r) }
+
+// WithEvents enqueues a GreetingsTopic event in o with each greeting added,
+// in the same transaction, and notifies n once it is committed. Without it
+// no event is enqueued.
+func WithEvents(o Outbox, n Notifier) GreetingsOption {
+	return greetingsOptionFunc(func(r *Greetings) {
+		r.outbox, r.notifier = o, n
+	})
+}
+
+// Greetings is the service.GreetingRepository in the greetings table, an
+// example of a repository of the service.
+type Greetings struct {
+	db       *sql.DB
+	outbox   Outbox
+	notifier Notifier
+}
+
+// NewGreetings returns the repository of the greetings in db.
+func NewGreetings(db *sql.DB, opts ...GreetingsOption) *Greetings {
+	r := &Greetings{db: db}
+	for _, o := range opts {
+		o.apply(r)
+	}
+	return r
+}
+
+// greetingEvent is the payload of a GreetingsTopic event.
+type greetingEvent struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Message    string    `json:"message"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+// Add inserts g and enqueues its event in one transaction, so that both are
+// committed or neither is.
+func (r *Greetings) Add(ctx context.Context, g service.Greeting) (_ service.Greeting, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return service.Greeting{}, err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, ignoreDone(tx.Rollback()))
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO greetings (name, message, create_time) VALUES (?, ?, ?)`,
+		g.Name, g.Message, g.CreateTime.UnixMicro())
+	if err != nil {
+		return service.Greeting{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return service.Greeting{}, err
+	}
+	g.ID = strconv.FormatInt(id, 10)
+
+	if r.outbox != nil {
+		var payload []byte
+		payload, err = json.Marshal(greetingEvent{ID: g.ID, Name: g.Name, Message: g.Message, CreateTime: g.CreateTime})
+		if err != nil {
+			return service.Greeting{}, err
+		}
+		err = r.outbox.Enqueue(ctx, tx, &messaging.Message{Topic: GreetingsTopic, Key: g.Name, Payload: payload})
+		if err != nil {
+			return service.Greeting{}, fmt.Errorf("enqueue event: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return service.Greeting{}, err
+	}
+	if r.notifier != nil {
+		r.notifier.Notify()
+	}
+	return g, nil
+}
+
+func (r *Greetings) Recent(ctx context.Context, n int) ([]service.Greeting, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, name, message, create_time FROM greetings ORDER BY id DESC LIMIT ?`, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var greetings []service.Greeting
+	for rows.Next() {
+		var (
+			g          service.Greeting
+			id, create int64
+		)
+		if err = rows.Scan(&id, &g.Name, &g.Message, &create); err != nil {
+			return nil, err
+		}
+		g.ID, g.CreateTime = strconv.FormatInt(id, 10), time.UnixMicro(create).UTC()
+		greetings = append(greetings, g)
+	}
+	slices.Reverse(greetings)
+	return greetings, rows.Err()
+}
@@ -0,0 +1,69 @@
+// The test is external to package storage: it uses the outbox, which
+// depends on storage.
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ravilushqa/boilerplate/internal/messaging"
+	"github.com/ravilushqa/boilerplate/internal/outbox"
+	"github.com/ravilushqa/boilerplate/internal/service"
+	"github.com/ravilushqa/boilerplate/internal/storage"
+)
+
+type notifier struct{ calls int }
+
+func (n *notifier) Notify() { n.calls++ }
+
+// failingOutbox enqueues the events, then fails the transaction.
+type failingOutbox struct{ storage.Outbox }
+
+func (o failingOutbox) Enqueue(ctx context.Context, tx storage.Execer, msgs ...*messaging.Message) error {
+	if err := o.Outbox.Enqueue(ctx, tx, msgs...); err != nil {
+		return err
+	}
+	return errors.New("outbox full")
+}
+
+func TestGreetings_events(t *testing.T) {
+	ctx := context.Background()
+	db, err := storage.Open(slog.Default(), storage.Config{Driver: "sqlite3", DSN: filepath.Join(t.TempDir(), "app.db")},
+		storage.WithMigrations(storage.Migrations()))
+	require.NoError(t, err)
+	require.NoError(t, db.Start(ctx))
+	defer func() { _ = db.Stop(ctx) }()
+	store, n := outbox.NewSQLStore(db.DB), &notifier{}
+
+	// the greeting and its event are committed together
+	greetings := storage.NewGreetings(db.DB, storage.WithEvents(store, n))
+	g, err := greetings.Add(ctx, service.Greeting{Name: "World", Message: "Hello World", CreateTime: time.Now().UTC()})
+	require.NoError(t, err)
+	events, err := store.Pending(ctx, time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, storage.GreetingsTopic, events[0].Message.Topic)
+	require.Equal(t, "World", events[0].Message.Key)
+	require.JSONEq(t, `{"id":"`+g.ID+`","name":"World","message":"Hello World","create_time":"`+
+		g.CreateTime.Format(time.RFC3339Nano)+`"}`, string(events[0].Message.Payload))
+	require.Equal(t, 1, n.calls)
+
+	// or rolled back together
+	greetings = storage.NewGreetings(db.DB, storage.WithEvents(failingOutbox{store}, n))
+	_, err = greetings.Add(ctx, service.Greeting{Name: "Bob", Message: "Hello Bob", CreateTime: time.Now().UTC()})
+	require.ErrorContains(t, err, "enqueue event: outbox full")
+	recent, err := greetings.Recent(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, recent, 1)
+	events, err = store.Pending(ctx, time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, 1, n.calls, "nothing was committed")
+}
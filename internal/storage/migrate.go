@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrations returns the migrations of the service, embedded in the binary.
+func Migrations() fs.FS {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// migrationFile matches the files of a migration, e.g. 0001_greetings.up.sql
+// and 0001_greetings.down.sql.
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration changes the schema of the database with its Up statements,
+// reverted by its Down ones.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up, Down string
+}
+
+// LoadMigrations reads the migrations in the root of fsys, ordered by
+// version. A migration is a <version>_<name>.up.sql file and an optional
+// <version>_<name>.down.sql file reverting it; a file may hold several
+// statements when the driver allows it, as SQLite does.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		match := migrationFile.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", e.Name(), err)
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.Name, match[2])
+		}
+		b, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", e.Name(), err)
+		}
+		if match[3] == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	var ms []Migration
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		ms = append(ms, *m)
+	}
+	slices.SortFunc(ms, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	return ms, nil
+}
+
+// MigrationStatus tells whether a migration is applied.
+type MigrationStatus struct {
+	Migration
+	Applied     bool
+	AppliedTime time.Time
+}
+
+// Migrator applies migrations to a database, recording the applied ones in
+// its schema_migrations table. Each migration runs in a transaction, so
+// that a failing one leaves no trace where the database has transactional
+// DDL. Replicas migrating at once may fail on the version they both apply,
+// the database is left consistent.
+type Migrator struct {
+	l          *slog.Logger
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator returns a Migrator applying the migrations of fsys to db, see
+// LoadMigrations.
+func NewMigrator(l *slog.Logger, db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	ms, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{l: l, db: db, migrations: ms}, nil
+}
+
+// Up applies the pending migrations in version order and returns the
+// number applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		err = m.exec(ctx, mig.Up, `INSERT INTO schema_migrations (version, name, applied_time) VALUES (?, ?, ?)`,
+			mig.Version, mig.Name, time.Now().UnixMicro())
+		if err != nil {
+			return n, fmt.Errorf("apply migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		m.l.Info("[STORAGE] migration applied", slog.Int64("version", mig.Version), slog.String("name", mig.Name))
+		n++
+	}
+	return n, nil
+}
+
+// Down reverts up to steps of the applied migrations, latest first, and
+// returns the number reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, mig := range slices.Backward(m.migrations) {
+		if n == steps {
+			break
+		}
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if mig.Down == "" {
+			return n, fmt.Errorf("migration %d_%s has no down file", mig.Version, mig.Name)
+		}
+		err = m.exec(ctx, mig.Down, `DELETE FROM schema_migrations WHERE version = ?`, mig.Version)
+		if err != nil {
+			return n, fmt.Errorf("revert migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		m.l.Info("[STORAGE] migration reverted", slog.Int64("version", mig.Version), slog.String("name", mig.Name))
+		n++
+	}
+	return n, nil
+}
+
+// Status returns the status of each migration, in version order.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, len(m.migrations))
+	for i, mig := range m.migrations {
+		t, ok := applied[mig.Version]
+		statuses[i] = MigrationStatus{Migration: mig, Applied: ok, AppliedTime: t}
+	}
+	return statuses, nil
+}
+
+// applied returns the application time of the applied migrations by
+// version, creating the schema_migrations table.
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	_, err := m.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version      INTEGER PRIMARY KEY,
+			name         TEXT    NOT NULL,
+			applied_time INTEGER NOT NULL
+		)`)
+	if err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+	rows, err := m.db.QueryContext(ctx, `SELECT version, applied_time FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version, t int64
+		if err = rows.Scan(&version, &t); err != nil {
+			return nil, err
+		}
+		applied[version] = time.UnixMicro(t)
+	}
+	return applied, rows.Err()
+}
+
+// exec runs the statements of a migration and the query recording it in a
+// transaction.
+func (m *Migrator) exec(ctx context.Context, statements, record string, args ...any) (err error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, ignoreDone(tx.Rollback()))
+		}
+	}()
+	if _, err = tx.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ignoreDone drops the error of rolling back a transaction already done.
+func ignoreDone(err error) error {
+	if errors.Is(err, sql.ErrTxDone) {
+		return nil
+	}
+	return err
+}
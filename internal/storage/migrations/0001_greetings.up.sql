@@ -0,0 +1,7 @@
+-- Times are Unix microseconds.
+CREATE TABLE greetings (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	name        TEXT    NOT NULL,
+	message     TEXT    NOT NULL,
+	create_time INTEGER NOT NULL
+);
@@ -0,0 +1,16 @@
+-- The outbox.SQLStore table. Times are Unix microseconds.
+CREATE TABLE outbox (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	message_id   TEXT    NOT NULL,
+	topic        TEXT    NOT NULL,
+	message_key  TEXT    NOT NULL,
+	headers      TEXT    NOT NULL,
+	payload      BLOB    NOT NULL,
+	message_time INTEGER NOT NULL,
+	create_time  INTEGER NOT NULL,
+	attempts     INTEGER NOT NULL DEFAULT 0,
+	next_attempt INTEGER NOT NULL,
+	last_error   TEXT    NOT NULL DEFAULT ''
+);
+CREATE INDEX outbox_next_attempt ON outbox (next_attempt);
+CREATE INDEX outbox_key ON outbox (topic, message_key, id);
@@ -0,0 +1,132 @@
+// Package storage opens the SQL database of the service. The database is
+// optional: without a DSN the service keeps its state in memory.
+//
+// The SQLite driver of the template, github.com/mattn/go-sqlite3, is built
+// with cgo. Queries use ? placeholders, as SQLite and MySQL do. With SQLite
+// a busy timeout and WAL journal keep concurrent writers from failing, e.g.
+// file:app.db?_busy_timeout=5000&_journal_mode=WAL.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Config configures the connection pool.
+type Config struct {
+	// Driver is the database/sql driver, registered by importing it.
+	Driver string
+	DSN    string
+	// MaxOpenConns bounds the open connections, 10 by default. MaxIdleConns
+	// are kept open when idle, 5 by default.
+	MaxOpenConns int
+	MaxIdleConns int
+	// ConnMaxLifetime and ConnMaxIdleTime close connections open or idle
+	// for longer, 30m and 5m by default.
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// Option configures a DB.
+type Option interface {
+	apply(*DB)
+}
+
+type optionFunc func(*DB)
+
+func (f optionFunc) apply(db *DB) { f(db) }
+
+// WithMigrations applies the pending migrations of fsys when the DB starts,
+// see LoadMigrations.
+func WithMigrations(fsys fs.FS) Option {
+	return optionFunc(func(db *DB) {
+		db.migrations = fsys
+	})
+}
+
+// DB is a pool of connections to the database. It is a lifecycle
+// component: Start checks the database is reachable and migrates it, Stop
+// closes the pool once its users stopped.
+type DB struct {
+	*sql.DB
+	l          *slog.Logger
+	name       string
+	migrations fs.FS
+}
+
+// Execer runs statements, *sql.Tx and *sql.DB implement it.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Open returns the pool of cfg. No connection is made until Start.
+func Open(l *slog.Logger, cfg Config, opts ...Option) (*DB, error) {
+	if cfg.MaxOpenConns <= 0 {
+		cfg.MaxOpenConns = 10
+	}
+	if cfg.MaxIdleConns <= 0 {
+		cfg.MaxIdleConns = 5
+	}
+	if cfg.ConnMaxLifetime <= 0 {
+		cfg.ConnMaxLifetime = 30 * time.Minute
+	}
+	if cfg.ConnMaxIdleTime <= 0 {
+		cfg.ConnMaxIdleTime = 5 * time.Minute
+	}
+
+	sqlDB, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("open %s database: %w", cfg.Driver, err)
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(min(cfg.MaxIdleConns, cfg.MaxOpenConns))
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	db := &DB{DB: sqlDB, l: l, name: cfg.Driver}
+	for _, o := range opts {
+		o.apply(db)
+	}
+	return db, nil
+}
+
+func (db *DB) Start(ctx context.Context) error {
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("connect to %s database: %w", db.name, err)
+	}
+	db.l.Info("[STORAGE] connected", slog.String("driver", db.name))
+	if db.migrations == nil {
+		return nil
+	}
+	m, err := NewMigrator(db.l, db.DB, db.migrations)
+	if err != nil {
+		return err
+	}
+	_, err = m.Up(ctx)
+	return err
+}
+
+func (db *DB) Stop(context.Context) error {
+	db.l.Info("[STORAGE] closing", slog.String("driver", db.name))
+	return db.Close()
+}
+
+// Check reports whether the database is reachable, for the readiness
+// endpoint.
+func (db *DB) Check(ctx context.Context) error {
+	return db.PingContext(ctx)
+}
+
+// RegisterMetrics registers the go_sql_* metrics of the pool on reg, e.g.
+// go_sql_in_use_connections and go_sql_wait_duration_seconds_total, labeled
+// with the driver as db_name.
+func (db *DB) RegisterMetrics(reg prometheus.Registerer) error {
+	return reg.Register(collectors.NewDBStatsCollector(db.DB, db.name))
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ravilushqa/boilerplate/internal/listing"
+	"github.com/ravilushqa/boilerplate/internal/service"
+)
+
+// open returns a started DB in a temporary SQLite file.
+func open(t *testing.T, opts ...Option) *DB {
+	db, err := Open(slog.Default(), Config{Driver: "sqlite3", DSN: filepath.Join(t.TempDir(), "app.db")}, opts...)
+	require.NoError(t, err)
+	require.NoError(t, db.Start(context.Background()))
+	t.Cleanup(func() { _ = db.Stop(context.Background()) })
+	return db
+}
+
+func TestDB(t *testing.T) {
+	ctx := context.Background()
+	db := open(t, WithMigrations(Migrations()))
+	require.NoError(t, db.Check(ctx))
+	require.Equal(t, 10, db.Stats().MaxOpenConnections)
+
+	reg := prometheus.NewRegistry()
+	require.NoError(t, db.RegisterMetrics(reg))
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	names := map[string]bool{}
+	for _, mf := range mfs {
+		names[mf.GetName()] = true
+	}
+	require.True(t, names["go_sql_open_connections"])
+
+	require.NoError(t, db.Stop(ctx))
+	require.Error(t, db.Check(ctx))
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	db := open(t)
+	fsys := fstest.MapFS{
+		"0001_users.up.sql":    {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);")},
+		"0001_users.down.sql":  {Data: []byte("DROP TABLE users;")},
+		"0002_emails.up.sql":   {Data: []byte("ALTER TABLE users ADD COLUMN email TEXT; CREATE INDEX users_email ON users (email);")},
+		"0002_emails.down.sql": {Data: []byte("DROP INDEX users_email; ALTER TABLE users DROP COLUMN email;")},
+		"0003_broken.up.sql":   {Data: []byte("CREATE TABLE orders (id INTEGER PRIMARY KEY); INSERT INTO missing VALUES (1);")},
+		"README.md":            {Data: []byte("ignored")},
+	}
+	m, err := NewMigrator(slog.Default(), db.DB, fsys)
+	require.NoError(t, err)
+
+	// a failing migration is rolled back and stops the ones after it
+	n, err := m.Up(ctx)
+	require.ErrorContains(t, err, "apply migration 3_broken")
+	require.Equal(t, 2, n)
+	_, err = db.Exec("INSERT INTO users (id, email) VALUES (1, 'a@example.com')")
+	require.NoError(t, err)
+	_, err = db.Exec("SELECT * FROM orders")
+	require.Error(t, err)
+
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, status, 3)
+	require.True(t, status[0].Applied)
+	require.False(t, status[0].AppliedTime.IsZero())
+	require.Equal(t, "emails", status[1].Name)
+	require.True(t, status[1].Applied)
+	require.False(t, status[2].Applied)
+
+	delete(fsys, "0003_broken.up.sql")
+	m, err = NewMigrator(slog.Default(), db.DB, fsys)
+	require.NoError(t, err)
+	n, err = m.Up(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	n, err = m.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	_, err = db.Exec("SELECT email FROM users")
+	require.Error(t, err)
+	n, err = m.Down(ctx, 5)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	status, err = m.Status(ctx)
+	require.NoError(t, err)
+	require.False(t, status[0].Applied)
+}
+
+func TestLoadMigrations(t *testing.T) {
+	ms, err := LoadMigrations(Migrations())
+	require.NoError(t, err)
+	require.Equal(t, "greetings", ms[0].Name)
+	require.NotEmpty(t, ms[0].Down)
+
+	_, err = LoadMigrations(fstest.MapFS{"0001_users.down.sql": {}})
+	require.EqualError(t, err, "migration 1_users has no up file")
+	_, err = LoadMigrations(fstest.MapFS{"0001_users.up.sql": {Data: []byte("-")}, "0001_people.down.sql": {}})
+	require.ErrorContains(t, err, "migration 1 is named both")
+}
+
+func TestGreetings(t *testing.T) {
+	ctx := context.Background()
+	db := open(t, WithMigrations(Migrations()))
+	g := service.NewGreeter(service.WithRepository(NewGreetings(db.DB)))
+	for _, name := range []string{"Alice", "Bob", "Carol"} {
+		_, err := g.Greet(ctx, name)
+		require.NoError(t, err)
+	}
+
+	page, next, err := g.ListGreetings(ctx, listing.Request{PageSize: 2, OrderBy: "name desc"})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	require.Equal(t, "Carol", page[0].Name)
+	require.Equal(t, "3", page[0].ID)
+	require.Equal(t, "Hello Bob", page[1].Message)
+	require.NotEmpty(t, next)
+
+	recent, err := NewGreetings(db.DB).Recent(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, "Bob", recent[0].Name)
+	require.Equal(t, "Carol", recent[1].Name)
+	require.WithinDuration(t, page[0].CreateTime, recent[1].CreateTime, 0)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -10,14 +11,27 @@ import (
 	"time"
 
 	"github.com/gophermodz/http/httpinfra"
-	"github.com/gorilla/mux"
 	"github.com/jessevdk/go-flags"
 	"github.com/lmittmann/tint"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/automaxprocs/maxprocs"
-	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/keepalive"
 
+	"github.com/ravilushqa/boilerplate/internal/app"
 	"github.com/ravilushqa/boilerplate/internal/app/grpc"
+	"github.com/ravilushqa/boilerplate/internal/app/grpc/interceptors"
 	"github.com/ravilushqa/boilerplate/internal/app/http"
+	"github.com/ravilushqa/boilerplate/internal/app/http/stream"
+	"github.com/ravilushqa/boilerplate/internal/app/infra"
+	"github.com/ravilushqa/boilerplate/internal/di"
+	"github.com/ravilushqa/boilerplate/internal/jobs"
+	"github.com/ravilushqa/boilerplate/internal/lifecycle"
+	"github.com/ravilushqa/boilerplate/internal/memlimit"
+	"github.com/ravilushqa/boilerplate/internal/messaging"
+	"github.com/ravilushqa/boilerplate/internal/operations"
+	"github.com/ravilushqa/boilerplate/internal/outbox"
+	"github.com/ravilushqa/boilerplate/internal/storage"
 )
 
 var (
@@ -32,10 +46,79 @@ var opts struct {
 	HTTPAddress string `long:"http-address" env:"HTTP_ADDRESS" description:"HTTP address" default:":8080"`
 	GRPCAddress string `long:"grpc-address" env:"GRPC_ADDRESS" description:"GRPC address" default:":50051"`
 	InfraPort   int    `long:"infra-port" env:"INFRA_PORT" description:"Infra port" default:"8081"`
+
+	HTTPCompressionMinSize int           `long:"http-compression-min-size" env:"HTTP_COMPRESSION_MIN_SIZE" description:"Smallest HTTP response compressed in bytes, negative disables compression" default:"1024"`
+	HTTPCacheSize          int           `long:"http-cache-size" env:"HTTP_CACHE_SIZE" description:"Number of GET responses kept in the in-process response cache, 0 disables it" default:"1000"`
+	HTTPValidateRequests   bool          `long:"http-validate-requests" env:"HTTP_VALIDATE_REQUESTS" description:"Reject HTTP requests not matching the OpenAPI document; outside production responses are checked too"`
+	HTTPStreamMaxConns     int           `long:"http-stream-max-conns" env:"HTTP_STREAM_MAX_CONNS" description:"Max open event streams and WebSockets, 0 is unlimited" default:"1000"`
+	HTTPStreamHeartbeat    time.Duration `long:"http-stream-heartbeat" env:"HTTP_STREAM_HEARTBEAT" description:"Interval of event stream heartbeats and WebSocket pings" default:"15s"`
+
+	GRPCMaxConnectionIdle     time.Duration `long:"grpc-max-connection-idle" env:"GRPC_MAX_CONNECTION_IDLE" description:"Close GRPC connections idle for this long, 0 is infinite"`
+	GRPCMaxConnectionAge      time.Duration `long:"grpc-max-connection-age" env:"GRPC_MAX_CONNECTION_AGE" description:"Max GRPC connection age before clients are asked to reconnect, 0 is infinite" default:"5m"`
+	GRPCMaxConnectionAgeGrace time.Duration `long:"grpc-max-connection-age-grace" env:"GRPC_MAX_CONNECTION_AGE_GRACE" description:"Time for in-flight RPCs to finish after max connection age" default:"30s"`
+	GRPCKeepaliveTime         time.Duration `long:"grpc-keepalive-time" env:"GRPC_KEEPALIVE_TIME" description:"Ping idle GRPC clients after this long" default:"1m"`
+	GRPCKeepaliveTimeout      time.Duration `long:"grpc-keepalive-timeout" env:"GRPC_KEEPALIVE_TIMEOUT" description:"Close GRPC connections not answering a ping within this long" default:"20s"`
+	GRPCKeepaliveMinTime      time.Duration `long:"grpc-keepalive-min-time" env:"GRPC_KEEPALIVE_MIN_TIME" description:"Min interval allowed between client pings" default:"10s"`
+	GRPCKeepaliveNoStream     bool          `long:"grpc-keepalive-permit-without-stream" env:"GRPC_KEEPALIVE_PERMIT_WITHOUT_STREAM" description:"Allow client pings without active streams"`
+	GRPCMaxRecvMsgSize        int           `long:"grpc-max-recv-msg-size" env:"GRPC_MAX_RECV_MSG_SIZE" description:"Max GRPC message size received in bytes" default:"4194304"`
+	GRPCMaxSendMsgSize        int           `long:"grpc-max-send-msg-size" env:"GRPC_MAX_SEND_MSG_SIZE" description:"Max GRPC message size sent in bytes" default:"4194304"`
+	GRPCMaxConcurrentStreams  uint32        `long:"grpc-max-concurrent-streams" env:"GRPC_MAX_CONCURRENT_STREAMS" description:"Max concurrent streams per GRPC connection, 0 is unlimited"`
+	GRPCConnectionTimeout     time.Duration `long:"grpc-connection-timeout" env:"GRPC_CONNECTION_TIMEOUT" description:"GRPC connection establishment timeout" default:"120s"`
+	GRPCChannelz              bool          `long:"grpc-channelz" env:"GRPC_CHANNELZ" description:"Register the GRPC channelz service"`
+	GRPCLogPayloadMethods     []string      `long:"grpc-log-payload-method" env:"GRPC_LOG_PAYLOAD_METHODS" env-delim:"," description:"Full GRPC method name whose payloads are logged, e.g. /api.v1.Greeter/Greet"`
+	GRPCLogPayloadMaxSize     int           `long:"grpc-log-payload-max-size" env:"GRPC_LOG_PAYLOAD_MAX_SIZE" description:"Max logged GRPC payload size in bytes" default:"1024"`
+	GRPCLogRedactFields       []string      `long:"grpc-log-redact-field" env:"GRPC_LOG_REDACT_FIELDS" env-delim:"," description:"Proto field name redacted in logged GRPC payloads" default:"password" default:"token"`
+
+	IdempotencyTTL time.Duration `long:"idempotency-ttl" env:"IDEMPOTENCY_TTL" description:"How long responses to requests with an idempotency key are replayed" default:"24h"`
+
+	PageTokenSecret string        `long:"page-token-secret" env:"PAGE_TOKEN_SECRET" description:"Key signing list page tokens, shared by replicas; random per process when empty"`
+	PageTokenTTL    time.Duration `long:"page-token-ttl" env:"PAGE_TOKEN_TTL" description:"How long list page tokens stay valid, 0 is forever" default:"24h"`
+
+	JobWorkers      int           `long:"job-workers" env:"JOB_WORKERS" description:"Number of background jobs run at once" default:"10"`
+	JobQueueSize    int           `long:"job-queue-size" env:"JOB_QUEUE_SIZE" description:"Number of background jobs waiting for a worker" default:"100"`
+	JobDrainTimeout time.Duration `long:"job-drain-timeout" env:"JOB_DRAIN_TIMEOUT" description:"Time queued and running background jobs have to finish on shutdown, below the shutdown timeout" default:"10s"`
+
+	OutboxInterval time.Duration `long:"outbox-interval" env:"OUTBOX_INTERVAL" description:"How often the outbox is polled for events to publish" default:"1s"`
+
+	DBDriver          string        `long:"db-driver" env:"DB_DRIVER" description:"database/sql driver of the database" default:"sqlite3"`
+	DBDSN             string        `long:"db-dsn" env:"DB_DSN" description:"Data source name of the database, state is kept in memory when empty"`
+	DBMaxOpenConns    int           `long:"db-max-open-conns" env:"DB_MAX_OPEN_CONNS" description:"Max open database connections" default:"10"`
+	DBMaxIdleConns    int           `long:"db-max-idle-conns" env:"DB_MAX_IDLE_CONNS" description:"Max idle database connections kept open" default:"5"`
+	DBConnMaxLifetime time.Duration `long:"db-conn-max-lifetime" env:"DB_CONN_MAX_LIFETIME" description:"Close database connections open for this long" default:"30m"`
+	DBConnMaxIdleTime time.Duration `long:"db-conn-max-idle-time" env:"DB_CONN_MAX_IDLE_TIME" description:"Close database connections idle for this long" default:"5m"`
+	DBMigrate         bool          `long:"db-migrate" env:"DB_MIGRATE" description:"Apply pending database migrations on start"`
+
+	OperationsTTL time.Duration `long:"operations-ttl" env:"OPERATIONS_TTL" description:"How long done long-running operations are kept" default:"24h"`
+
+	ShutdownTimeout time.Duration `long:"shutdown-timeout" env:"SHUTDOWN_TIMEOUT" description:"Max time each component may take to stop" default:"15s"`
+
+	MemLimitRatio float64 `long:"memlimit-ratio" env:"MEMLIMIT_RATIO" description:"Share of the cgroup memory limit used as GOMEMLIMIT, 0 disables; GOMEMLIMIT env takes precedence" default:"0.9"`
+
+	DebugEnabled     bool          `long:"debug-enabled" env:"DEBUG_ENABLED" description:"Expose pprof, expvar, goroutine dumps and trace capture on the infra server"`
+	DebugToken       string        `long:"debug-token" env:"DEBUG_TOKEN" description:"Bearer token required by the diagnostics endpoints"`
+	DebugMaxDuration time.Duration `long:"debug-max-duration" env:"DEBUG_MAX_DURATION" description:"Max duration of profile and trace captures" default:"30s"`
+	ProfileDir       string        `long:"profile-dir" env:"PROFILE_DIR" description:"Directory for continuous CPU/heap profiles, disabled when empty"`
+	ProfileInterval  time.Duration `long:"profile-interval" env:"PROFILE_INTERVAL" description:"Continuous profiling interval" default:"1m"`
+	ProfileDuration  time.Duration `long:"profile-duration" env:"PROFILE_DURATION" description:"CPU profile duration per interval" default:"10s"`
+	ProfileKeep      int           `long:"profile-keep" env:"PROFILE_KEEP" description:"Number of profiles of each kind to keep" default:"10"`
+}
+
+// migrateCmd is the migrate command, run instead of the servers.
+var migrateCmd struct {
+	Steps int `long:"steps" description:"Number of migrations reverted by down" default:"1"`
+	Args  struct {
+		Action string `positional-arg-name:"up|down|status"`
+	} `positional-args:"yes" required:"yes"`
 }
 
 func main() {
-	_, err := flags.Parse(&opts)
+	parser := flags.NewParser(&opts, flags.Default)
+	parser.SubcommandsOptional = true
+	_, err := parser.AddCommand("migrate", "Migrate the database", "Apply (up), revert (down) or list (status) the database migrations.", &migrateCmd)
+	if err != nil {
+		panic(err)
+	}
+	_, err = parser.Parse()
 	if err != nil {
 		if err.(*flags.Error).Type != flags.ErrHelp {
 			panic(err)
@@ -47,38 +130,226 @@ func main() {
 	_, _ = maxprocs.Set(maxprocs.Logger(func(s string, i ...interface{}) {
 		l.Info(fmt.Sprintf(s, i...))
 	}))
+	if _, err = memlimit.Set(memlimit.Ratio(opts.MemLimitRatio), memlimit.Logger(func(s string, i ...interface{}) {
+		l.Info(fmt.Sprintf(s, i...))
+	})); err != nil {
+		l.Warn("memlimit: failed to set GOMEMLIMIT", slog.Any("error", err))
+	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	if parser.Active != nil && parser.Active.Name == "migrate" {
+		if err = migrate(ctx, l); err != nil {
+			l.Error("migrate failed", slog.Any("error", err))
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err = run(ctx, l); err != nil {
-		l.Error("run failed", err)
+		l.Error("run failed", slog.Any("error", err))
+	}
+}
+
+func storageConfig() storage.Config {
+	return storage.Config{
+		Driver:          opts.DBDriver,
+		DSN:             opts.DBDSN,
+		MaxOpenConns:    opts.DBMaxOpenConns,
+		MaxIdleConns:    opts.DBMaxIdleConns,
+		ConnMaxLifetime: opts.DBConnMaxLifetime,
+		ConnMaxIdleTime: opts.DBConnMaxIdleTime,
+	}
+}
+
+// migrate runs the migrate command on the embedded migrations.
+func migrate(ctx context.Context, l *slog.Logger) error {
+	if opts.DBDSN == "" {
+		return errors.New("migrate requires --db-dsn")
+	}
+	db, err := storage.Open(l, storageConfig())
+	if err != nil {
+		return err
+	}
+	if err = db.Start(ctx); err != nil {
+		return err
+	}
+	defer func() { _ = db.Stop(ctx) }()
+	m, err := storage.NewMigrator(l, db.DB, storage.Migrations())
+	if err != nil {
+		return err
+	}
+
+	switch migrateCmd.Args.Action {
+	case "up":
+		_, err = m.Up(ctx)
+		return err
+	case "down":
+		_, err = m.Down(ctx, migrateCmd.Steps)
+		return err
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied " + s.AppliedTime.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d %-30s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate action %q, want up, down or status", migrateCmd.Args.Action)
 	}
 }
 
 func run(ctx context.Context, l *slog.Logger) error {
-	eg, ctx := errgroup.WithContext(ctx)
+	if opts.DebugEnabled && opts.DebugToken == "" {
+		return errors.New("debug endpoints require --debug-token")
+	}
 
-	// HTTP`
-	r := mux.NewRouter()
-	httpServer := http.New(l, r, opts.HTTPAddress)
-	eg.Go(func() error {
-		return httpServer.Run(ctx)
-	})
+	buildInfo := infra.ReadBuildInfo(Version)
+	if err := infra.RegisterMetrics(prometheus.DefaultRegisterer, buildInfo); err != nil {
+		return fmt.Errorf("register metrics: %w", err)
+	}
 
-	// GRPC
-	grpcServer := grpc.New(l, opts.GRPCAddress)
-	eg.Go(func() error {
-		return grpcServer.Run(ctx)
-	})
+	lc := lifecycle.New(l, opts.ShutdownTimeout)
 
 	// Infra
-	infraServer := httpinfra.New(ctx, l, httpinfra.WithPort(opts.InfraPort), httpinfra.WithVersion(Version))
-	eg.Go(func() error {
-		return infraServer.Run(ctx)
+	infraServer := infra.New(
+		l,
+		httpinfra.New(ctx, l, httpinfra.WithPort(opts.InfraPort), httpinfra.WithVersion(buildInfo.Version), httpinfra.WithRevision(buildInfo.Revision)),
+		fmt.Sprintf(":%d", opts.InfraPort),
+		infra.DebugConfig{Enabled: opts.DebugEnabled, Token: opts.DebugToken, MaxDuration: opts.DebugMaxDuration},
+	)
+	infraServer.AddCheck("lifecycle", lc.Check)
+	lc.AddRunner("infra", infraServer)
+
+	if opts.ProfileDir != "" {
+		profiler := infra.NewProfiler(l, opts.ProfileDir, opts.ProfileInterval, opts.ProfileDuration, opts.ProfileKeep)
+		lc.AddRunner("profiler", profiler)
+	}
+
+	grpcOpts := []grpc.Option{
+		grpc.WithKeepalive(keepalive.ServerParameters{
+			MaxConnectionIdle:     opts.GRPCMaxConnectionIdle,
+			MaxConnectionAge:      opts.GRPCMaxConnectionAge,
+			MaxConnectionAgeGrace: opts.GRPCMaxConnectionAgeGrace,
+			Time:                  opts.GRPCKeepaliveTime,
+			Timeout:               opts.GRPCKeepaliveTimeout,
+		}),
+		grpc.WithKeepaliveEnforcement(keepalive.EnforcementPolicy{
+			MinTime:             opts.GRPCKeepaliveMinTime,
+			PermitWithoutStream: opts.GRPCKeepaliveNoStream,
+		}),
+		grpc.WithMaxMsgSize(opts.GRPCMaxRecvMsgSize, opts.GRPCMaxSendMsgSize),
+		grpc.WithMaxConcurrentStreams(opts.GRPCMaxConcurrentStreams),
+		grpc.WithConnectionTimeout(opts.GRPCConnectionTimeout),
+		grpc.WithHealthCheck(lc.Check, time.Second),
+		grpc.WithLogging(interceptors.LoggingConfig{
+			PayloadMethods: opts.GRPCLogPayloadMethods,
+			MaxPayloadSize: opts.GRPCLogPayloadMaxSize,
+			RedactFields:   opts.GRPCLogRedactFields,
+		}),
+	}
+	if opts.GRPCChannelz {
+		grpcOpts = append(grpcOpts, grpc.WithChannelz())
+	}
+
+	c := di.New()
+	app.Provide(c, l, app.Config{
+		HTTPAddress:            opts.HTTPAddress,
+		GRPCAddress:            opts.GRPCAddress,
+		GRPCOptions:            grpcOpts,
+		IdempotencyTTL:         opts.IdempotencyTTL,
+		PageTokenSecret:        opts.PageTokenSecret,
+		PageTokenTTL:           opts.PageTokenTTL,
+		OperationsTTL:          opts.OperationsTTL,
+		HTTPCacheSize:          opts.HTTPCacheSize,
+		HTTPCompressionMinSize: opts.HTTPCompressionMinSize,
+		HTTPValidateRequests:   opts.HTTPValidateRequests,
+		HTTPValidateResponses:  opts.HTTPValidateRequests && opts.Env != "production",
+		HTTPStreams:            stream.Config{MaxConns: opts.HTTPStreamMaxConns, Heartbeat: opts.HTTPStreamHeartbeat},
+		Jobs:                   jobs.PoolConfig{Workers: opts.JobWorkers, QueueSize: opts.JobQueueSize, DrainTimeout: opts.JobDrainTimeout},
+		Outbox:                 outbox.RelayConfig{Interval: opts.OutboxInterval},
+		Storage:                storageConfig(),
+		StorageMigrate:         opts.DBMigrate,
 	})
 
-	return eg.Wait()
+	// The database is optional, the components using it stop before it
+	// closes
+	var storageDeps []string
+	db, err := di.Resolve[*storage.DB](c)
+	if err != nil {
+		return err
+	}
+	if db != nil {
+		if err = db.RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+			return fmt.Errorf("register storage metrics: %w", err)
+		}
+		infraServer.AddCheck("storage", db.Check)
+		lc.Add("storage", db)
+		storageDeps = append(storageDeps, "storage")
+	}
+	deps := append([]string{"outbox"}, storageDeps...)
+
+	// Jobs run in the background, scheduled jobs may queue more. The servers
+	// stop first, so no job is queued once the pool drains
+	pool, err := di.Resolve[*jobs.Pool](c)
+	if err != nil {
+		return err
+	}
+	lc.AddRunner("jobs", pool, lifecycle.DependsOn(deps...))
+	scheduler, err := di.Resolve[*jobs.Scheduler](c)
+	if err != nil {
+		return err
+	}
+	lc.AddRunner("scheduler", scheduler, lifecycle.DependsOn("jobs"))
+
+	// The relay publishes the events enqueued by the servers, consumers and
+	// jobs, it stops after them
+	relay, err := di.Resolve[*outbox.Relay](c)
+	if err != nil {
+		return err
+	}
+	lc.AddRunner("outbox", relay, lifecycle.DependsOn(storageDeps...))
+
+	// Consumers stop before the jobs and operations they may start
+	consumers, err := di.Resolve[[]*messaging.Consumer](c)
+	if err != nil {
+		return err
+	}
+	for _, consumer := range consumers {
+		lc.AddRunner("consumer "+consumer.Name(), consumer, lifecycle.DependsOn(append([]string{"jobs", "operations"}, deps...)...))
+	}
+
+	// Operations run tasks started by the servers, which stop first so none
+	// are started once the running ones are canceled. The tasks store and
+	// enqueue like the servers do, the database and the relay stop after them
+	ops, err := di.Resolve[*operations.Manager](c)
+	if err != nil {
+		return err
+	}
+	lc.AddRunner("operations", ops, lifecycle.DependsOn(deps...))
+
+	// HTTP
+	httpServer, err := di.Resolve[*http.Server](c)
+	if err != nil {
+		return err
+	}
+	lc.AddRunner("http", httpServer, lifecycle.DependsOn(append([]string{"infra", "operations", "jobs"}, deps...)...))
+
+	// GRPC
+	grpcServer, err := di.Resolve[*grpc.Server](c)
+	if err != nil {
+		return err
+	}
+	lc.AddRunner("grpc", grpcServer, lifecycle.DependsOn(append([]string{"infra", "operations", "jobs"}, deps...)...))
+
+	return lc.Run(ctx)
 }
 
 func initLogger() *slog.Logger {
@@ -0,0 +1,214 @@
+// Package client is a ready-to-use client for the Greeter API, over gRPC
+// (Client) and over the REST surface (HTTPClient).
+package client
+
+import (
+	"crypto/tls"
+	"errors"
+	"strings"
+	"time"
+
+	grpcprometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+
+	apiv1 "github.com/ravilushqa/boilerplate/api/v1"
+	_ "github.com/ravilushqa/boilerplate/pkg/encoding/zstd"
+)
+
+// DefaultServiceConfig balances calls round robin over all endpoints and
+// retries them on UNAVAILABLE with exponential backoff. Streams are only
+// retried until the first response is received. grpc-go does not implement
+// hedgingPolicy, hedging is done by the interceptor set up with WithHedging.
+//
+// A method entry replaces the service one, so Greet and GreetLater get their
+// timeout and no retryPolicy: each call stores a greeting, it is neither
+// retried nor hedged.
+// GreetStream and Chat store a greeting per request too and get an entry
+// without retryPolicy, and without a timeout as streams are long-lived.
+// Methods hedged with WithHedging need such an entry too, so that the
+// attempts of a hedged call are not retried: at most
+// HedgingPolicy.MaxAttempts RPCs are sent per call, not that many times the
+// retry maxAttempts.
+const DefaultServiceConfig = `{
+  "loadBalancingConfig": [{"round_robin": {}}],
+  "methodConfig": [
+    {
+      "name": [{"service": "api.v1.Greeter"}],
+      "retryPolicy": {
+        "maxAttempts": 4,
+        "initialBackoff": "0.1s",
+        "maxBackoff": "1s",
+        "backoffMultiplier": 2,
+        "retryableStatusCodes": ["UNAVAILABLE"]
+      }
+    },
+    {
+      "name": [
+        {"service": "api.v1.Greeter", "method": "Greet"},
+        {"service": "api.v1.Greeter", "method": "GreetLater"}
+      ],
+      "timeout": "5s"
+    },
+    {
+      "name": [
+        {"service": "api.v1.Greeter", "method": "GreetStream"},
+        {"service": "api.v1.Greeter", "method": "Chat"}
+      ]
+    }
+  ]
+}`
+
+const scheme = "greeter"
+
+type config struct {
+	tls           *tls.Config
+	serviceConfig string
+	hedging       map[string]HedgingPolicy
+	userAgent     string
+	compressor    string
+	unary         []grpc.UnaryClientInterceptor
+	stream        []grpc.StreamClientInterceptor
+	dialOpts      []grpc.DialOption
+}
+
+// Option configures the Client.
+type Option interface {
+	apply(*config)
+}
+
+type optionFunc func(*config)
+
+func (o optionFunc) apply(c *config) {
+	o(c)
+}
+
+// WithTLS enables TLS. Connections are insecure by default.
+func WithTLS(cfg *tls.Config) Option {
+	return optionFunc(func(c *config) {
+		c.tls = cfg
+	})
+}
+
+// WithServiceConfig replaces DefaultServiceConfig.
+func WithServiceConfig(json string) Option {
+	return optionFunc(func(c *config) {
+		c.serviceConfig = json
+	})
+}
+
+// WithHedging sets the hedging policy of a unary method given by its full
+// name, e.g. "/api.v1.Greeter/Greet". Only idempotent methods may be hedged,
+// and their service config must have no retryPolicy, see
+// DefaultServiceConfig. A policy with MaxAttempts below 2 disables hedging
+// of the method.
+func WithHedging(method string, p HedgingPolicy) Option {
+	return optionFunc(func(c *config) {
+		c.hedging[method] = p
+	})
+}
+
+// WithCompressor compresses requests with the named compressor, "gzip" or
+// "zstd". The server answers with the same one. Calls are not compressed by
+// default.
+func WithCompressor(name string) Option {
+	return optionFunc(func(c *config) {
+		c.compressor = name
+	})
+}
+
+// WithUserAgent sets the user agent sent with every call.
+func WithUserAgent(ua string) Option {
+	return optionFunc(func(c *config) {
+		c.userAgent = ua
+	})
+}
+
+// WithUnaryInterceptors appends unary interceptors after the metrics one.
+func WithUnaryInterceptors(i ...grpc.UnaryClientInterceptor) Option {
+	return optionFunc(func(c *config) {
+		c.unary = append(c.unary, i...)
+	})
+}
+
+// WithStreamInterceptors appends stream interceptors after the metrics one.
+func WithStreamInterceptors(i ...grpc.StreamClientInterceptor) Option {
+	return optionFunc(func(c *config) {
+		c.stream = append(c.stream, i...)
+	})
+}
+
+// WithDialOptions appends raw grpc dial options.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return optionFunc(func(c *config) {
+		c.dialOpts = append(c.dialOpts, opts...)
+	})
+}
+
+// Client is a Greeter gRPC client.
+type Client struct {
+	apiv1.GreeterClient
+	conn *grpc.ClientConn
+}
+
+// New returns a client balancing calls over the given host:port endpoints.
+// Endpoints are static: no DNS or xDS resolver is involved, host names are
+// resolved by the dialer when a connection is established. Calls are traced
+// with the global OpenTelemetry provider and counted in the
+// grpc_client_* Prometheus metrics.
+func New(endpoints []string, opts ...Option) (*Client, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("client: no endpoints")
+	}
+	cfg := &config{
+		serviceConfig: DefaultServiceConfig,
+		hedging:       map[string]HedgingPolicy{},
+	}
+	for _, opt := range opts {
+		opt.apply(cfg)
+	}
+
+	addrs := make([]resolver.Address, 0, len(endpoints))
+	for _, e := range endpoints {
+		addrs = append(addrs, resolver.Address{Addr: e})
+	}
+	r := manual.NewBuilderWithScheme(scheme)
+	r.InitialState(resolver.State{Addresses: addrs})
+
+	creds := insecure.NewCredentials()
+	if cfg.tls != nil {
+		creds = credentials.NewTLS(cfg.tls)
+	}
+
+	dialOpts := append([]grpc.DialOption{
+		grpc.WithResolvers(r),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultServiceConfig(cfg.serviceConfig),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(append([]grpc.UnaryClientInterceptor{grpcprometheus.UnaryClientInterceptor, hedge(cfg.hedging)}, cfg.unary...)...),
+		grpc.WithChainStreamInterceptor(append([]grpc.StreamClientInterceptor{grpcprometheus.StreamClientInterceptor}, cfg.stream...)...),
+		grpc.WithConnectParams(grpc.ConnectParams{MinConnectTimeout: 5 * time.Second}),
+	}, cfg.dialOpts...)
+	if cfg.compressor != "" {
+		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(grpc.UseCompressor(cfg.compressor)))
+	}
+	if cfg.userAgent != "" {
+		dialOpts = append(dialOpts, grpc.WithUserAgent(cfg.userAgent))
+	}
+
+	conn, err := grpc.NewClient(scheme+":///"+strings.Join(endpoints, ","), dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{GreeterClient: apiv1.NewGreeterClient(conn), conn: conn}, nil
+}
+
+// Close closes the underlying connections.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
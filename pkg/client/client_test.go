@@ -0,0 +1,300 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apiv1 "github.com/ravilushqa/boilerplate/api/v1"
+)
+
+type greeter struct {
+	apiv1.UnimplementedGreeterServer
+	calls atomic.Int32
+	fail  bool
+	// stall blocks the first call until it is canceled
+	stall    bool
+	canceled atomic.Bool
+}
+
+func (g *greeter) Greet(ctx context.Context, req *apiv1.GreetRequest) (*apiv1.GreetResponse, error) {
+	n := g.calls.Add(1)
+	if g.fail {
+		return nil, status.Error(codes.Unavailable, "overloaded")
+	}
+	if g.stall && n == 1 {
+		<-ctx.Done()
+		g.canceled.Store(true)
+		return nil, ctx.Err()
+	}
+	return &apiv1.GreetResponse{Message: "Hello " + req.GetName()}, nil
+}
+
+func (g *greeter) GreetStream(*apiv1.GreetStreamRequest, apiv1.Greeter_GreetStreamServer) error {
+	g.calls.Add(1)
+	return status.Error(codes.Unavailable, "overloaded")
+}
+
+func (g *greeter) Chat(apiv1.Greeter_ChatServer) error {
+	g.calls.Add(1)
+	return status.Error(codes.Unavailable, "overloaded")
+}
+
+func (g *greeter) GreetLater(context.Context, *apiv1.GreetLaterRequest) (*longrunningpb.Operation, error) {
+	g.calls.Add(1)
+	return nil, status.Error(codes.Unavailable, "overloaded")
+}
+
+func serve(t *testing.T, g *greeter) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer()
+	apiv1.RegisterGreeterServer(s, g)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+func TestClient(t *testing.T) {
+	a, b := &greeter{}, &greeter{}
+	c, err := New([]string{serve(t, a), serve(t, b)})
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 10; i++ {
+		resp, err := c.Greet(ctx, &apiv1.GreetRequest{Name: "world"})
+		require.NoError(t, err)
+		require.Equal(t, "Hello world", resp.GetMessage())
+	}
+	// calls are balanced over both backends, Greet is sent once per call
+	require.Positive(t, a.calls.Load())
+	require.Positive(t, b.calls.Load())
+	require.EqualValues(t, 10, a.calls.Load()+b.calls.Load())
+}
+
+func TestClient_hedging(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	g := &greeter{stall: true}
+	c, err := New([]string{serve(t, g)}, WithHedging("/api.v1.Greeter/Greet", HedgingPolicy{MaxAttempts: 3, Delay: delay}))
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// the stalled first attempt is overtaken by the hedge sent after delay
+	start := time.Now()
+	resp, err := c.Greet(ctx, &apiv1.GreetRequest{Name: "world"})
+	require.NoError(t, err)
+	require.Equal(t, "Hello world", resp.GetMessage())
+	require.GreaterOrEqual(t, time.Since(start), delay)
+	require.Less(t, time.Since(start), time.Second)
+	require.EqualValues(t, 2, g.calls.Load())
+	require.Eventually(t, g.canceled.Load, time.Second, time.Millisecond, "the first attempt is canceled")
+
+	// a fast call sends no hedge
+	_, err = c.Greet(ctx, &apiv1.GreetRequest{Name: "world"})
+	require.NoError(t, err)
+	time.Sleep(2 * delay)
+	require.EqualValues(t, 3, g.calls.Load())
+}
+
+func TestClient_hedgingNotRetried(t *testing.T) {
+	g := &greeter{fail: true}
+	c, err := New([]string{serve(t, g)}, WithHedging("/api.v1.Greeter/Greet", HedgingPolicy{
+		MaxAttempts:   3,
+		Delay:         time.Second,
+		NonFatalCodes: []codes.Code{codes.Unavailable},
+	}))
+	require.NoError(t, err)
+	defer c.Close()
+
+	// each attempt fails at once, none of them is retried by grpc
+	_, err = c.Greet(context.Background(), &apiv1.GreetRequest{Name: "world"})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.EqualValues(t, 3, g.calls.Load())
+}
+
+func TestClient_greetingsNotRetried(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// every method storing a greeting fails once, without being retried
+	calls := map[string]func(c *Client) error{
+		"Greet": func(c *Client) error {
+			_, err := c.Greet(ctx, &apiv1.GreetRequest{Name: "world"})
+			return err
+		},
+		"GreetLater": func(c *Client) error {
+			_, err := c.GreetLater(ctx, &apiv1.GreetLaterRequest{Name: "world"})
+			return err
+		},
+		"GreetStream": func(c *Client) error {
+			s, err := c.GreetStream(ctx, &apiv1.GreetStreamRequest{Name: "world", Count: 1})
+			if err != nil {
+				return err
+			}
+			_, err = s.Recv()
+			return err
+		},
+		"Chat": func(c *Client) error {
+			s, err := c.Chat(ctx)
+			if err != nil {
+				return err
+			}
+			_, err = s.Recv()
+			return err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			g := &greeter{fail: true}
+			c, err := New([]string{serve(t, g)})
+			require.NoError(t, err)
+			defer c.Close()
+
+			require.Equal(t, codes.Unavailable, status.Code(call(c)))
+			require.EqualValues(t, 1, g.calls.Load())
+		})
+	}
+}
+
+func TestClient_compression(t *testing.T) {
+	for _, name := range []string{"gzip", "zstd"} {
+		c, err := New([]string{serve(t, &greeter{})}, WithCompressor(name))
+		require.NoError(t, err)
+
+		// the server fails calls with Unimplemented for unknown encodings
+		resp, err := c.Greet(context.Background(), &apiv1.GreetRequest{Name: "world"})
+		require.NoError(t, err, name)
+		require.Equal(t, "Hello world", resp.GetMessage())
+		require.NoError(t, c.Close())
+	}
+}
+
+func TestNew_noEndpoints(t *testing.T) {
+	_, err := New(nil)
+	require.Error(t, err)
+}
+
+func TestHTTPClient(t *testing.T) {
+	var (
+		calls     atomic.Int32
+		decodeErr atomic.Value
+		keys      sync.Map
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys.Store(r.Header.Get("Idempotency-Key"), true)
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path != "/v1/greet" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req struct{ Name string }
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			decodeErr.Store(err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.Name == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"name is required"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"greeting":"Hello ` + req.Name + `"}`))
+	}))
+	defer srv.Close()
+
+	c := NewHTTP(srv.URL, WithRetry(3, time.Millisecond, 10*time.Millisecond))
+	greeting, err := c.Greet(context.Background(), "world")
+	require.NoError(t, err)
+	require.Equal(t, "Hello world", greeting)
+	require.EqualValues(t, 2, calls.Load())
+	// the retry is sent with the key of the first attempt
+	var sent []string
+	keys.Range(func(k, _ any) bool {
+		sent = append(sent, k.(string))
+		return true
+	})
+	require.Len(t, sent, 1)
+	require.NotEmpty(t, sent[0])
+
+	_, err = c.Greet(context.Background(), "")
+	var e *Error
+	require.ErrorAs(t, err, &e)
+	require.Equal(t, http.StatusBadRequest, e.StatusCode)
+	require.Equal(t, "name is required", e.Message)
+	require.Nil(t, decodeErr.Load())
+}
+
+func TestHTTPClient_ListGreetings(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/greetings" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("order_by") == "age" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"cannot order by age"}`))
+			return
+		}
+		if q.Get("page_size") != "1" || q.Get("page_token") != "t1" || q.Get("filter") != `name = "World"` || q.Get("order_by") != "name desc" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"greetings":[{"id":"g1","name":"World","message":"Hello World","create_time":"2026-01-02T03:04:05Z"}],"next_page_token":"t2"}`))
+	}))
+	defer srv.Close()
+
+	c := NewHTTP(srv.URL)
+	greetings, next, err := c.ListGreetings(context.Background(), ListGreetingsRequest{
+		PageSize:  1,
+		PageToken: "t1",
+		Filter:    `name = "World"`,
+		OrderBy:   "name desc",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "t2", next)
+	require.Len(t, greetings, 1)
+	require.Equal(t, "g1", greetings[0].ID)
+	require.Equal(t, "Hello World", greetings[0].Message)
+	require.True(t, created.Equal(greetings[0].CreateTime))
+
+	_, _, err = c.ListGreetings(context.Background(), ListGreetingsRequest{OrderBy: "age"})
+	var e *Error
+	require.ErrorAs(t, err, &e)
+	require.Equal(t, "cannot order by age", e.Message)
+}
+
+func Test_canRetry(t *testing.T) {
+	post := func(ctx context.Context, key string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", nil).WithContext(ctx)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		return req
+	}
+	require.True(t, canRetry(httptest.NewRequest(http.MethodGet, "/", nil)))
+	require.False(t, canRetry(post(context.Background(), "")))
+	require.True(t, canRetry(post(context.Background(), "k1")))
+	require.True(t, canRetry(post(Retryable(context.Background()), "")))
+}
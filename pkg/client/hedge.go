@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// HedgingPolicy sends up to MaxAttempts copies of a call, each one Delay
+// after the previous or right away when the previous failed with one of
+// NonFatalCodes. The first successful response wins and the other attempts
+// are cancelled. Round robin balancing sends the copies to different
+// endpoints, so a slow or failing endpoint does not hold up the call.
+type HedgingPolicy struct {
+	MaxAttempts   int
+	Delay         time.Duration
+	NonFatalCodes []codes.Code
+}
+
+// DefaultHedgingPolicy suits idempotent methods, none is hedged by default.
+var DefaultHedgingPolicy = HedgingPolicy{
+	MaxAttempts:   3,
+	Delay:         100 * time.Millisecond,
+	NonFatalCodes: []codes.Code{codes.Unavailable, codes.ResourceExhausted},
+}
+
+func (p HedgingPolicy) nonFatal(err error) bool {
+	code := status.Code(err)
+	for _, c := range p.NonFatalCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+type attempt struct {
+	reply proto.Message
+	err   error
+}
+
+func hedge(policies map[string]HedgingPolicy) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		p, ok := policies[method]
+		out, isProto := reply.(proto.Message)
+		if !ok || p.MaxAttempts < 2 || !isProto {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		// buffered so attempts still running when the call returns do not leak
+		results := make(chan attempt, p.MaxAttempts)
+		send := func() {
+			r := proto.Clone(out)
+			proto.Reset(r)
+			go func() {
+				results <- attempt{reply: r, err: invoker(ctx, method, req, r, cc, opts...)}
+			}()
+		}
+
+		send()
+		sent, pending := 1, 1
+		timer := time.NewTimer(p.Delay)
+		defer timer.Stop()
+
+		var lastErr error
+		for pending > 0 {
+			select {
+			case <-timer.C:
+				if sent < p.MaxAttempts {
+					send()
+					sent++
+					pending++
+					timer.Reset(p.Delay)
+				}
+			case a := <-results:
+				pending--
+				if a.err == nil {
+					proto.Reset(out)
+					proto.Merge(out, a.reply)
+					return nil
+				}
+				lastErr = a.err
+				if !p.nonFatal(a.err) {
+					return a.err
+				}
+				if sent < p.MaxAttempts {
+					send()
+					sent++
+					pending++
+					timer.Reset(p.Delay)
+				}
+			}
+		}
+		return lastErr
+	}
+}
@@ -0,0 +1,323 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Error is a non-2xx response of the REST API.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("client: %d %s", e.StatusCode, e.Message)
+}
+
+type httpConfig struct {
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	userAgent   string
+}
+
+// HTTPOption configures the HTTPClient.
+type HTTPOption interface {
+	apply(*httpConfig)
+}
+
+type httpOptionFunc func(*httpConfig)
+
+func (o httpOptionFunc) apply(c *httpConfig) {
+	o(c)
+}
+
+// WithHTTPClient sets the client whose transport is wrapped with tracing and
+// retries, http.DefaultClient otherwise.
+func WithHTTPClient(hc *http.Client) HTTPOption {
+	return httpOptionFunc(func(c *httpConfig) {
+		c.client = hc
+	})
+}
+
+// WithRetry sets the attempts per request, 1 disables retries, and the
+// backoff before the first retry, doubled on each next one up to max.
+func WithRetry(attempts int, backoff, max time.Duration) HTTPOption {
+	return httpOptionFunc(func(c *httpConfig) {
+		c.maxAttempts = attempts
+		c.backoff = backoff
+		c.maxBackoff = max
+	})
+}
+
+// WithHTTPUserAgent sets the User-Agent header of every request.
+func WithHTTPUserAgent(ua string) HTTPOption {
+	return httpOptionFunc(func(c *httpConfig) {
+		c.userAgent = ua
+	})
+}
+
+// HTTPClient is a client of the Greeter REST API.
+type HTTPClient struct {
+	baseURL   string
+	client    *http.Client
+	userAgent string
+}
+
+// NewHTTP returns a client of the REST API served at baseURL, e.g.
+// "http://localhost:8080". Requests are traced with the global OpenTelemetry
+// provider and retried as described by retryTransport.
+func NewHTTP(baseURL string, opts ...HTTPOption) *HTTPClient {
+	cfg := &httpConfig{
+		client:      http.DefaultClient,
+		maxAttempts: 4,
+		backoff:     100 * time.Millisecond,
+		maxBackoff:  time.Second,
+	}
+	for _, opt := range opts {
+		opt.apply(cfg)
+	}
+
+	base := cfg.client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	hc := *cfg.client
+	hc.Transport = otelhttp.NewTransport(&retryTransport{
+		base:        base,
+		maxAttempts: cfg.maxAttempts,
+		backoff:     cfg.backoff,
+		maxBackoff:  cfg.maxBackoff,
+	})
+	return &HTTPClient{baseURL: strings.TrimRight(baseURL, "/"), client: &hc, userAgent: cfg.userAgent}
+}
+
+// Greet calls POST /v1/greet.
+func (c *HTTPClient) Greet(ctx context.Context, name string) (string, error) {
+	var resp struct {
+		Greeting string `json:"greeting"`
+	}
+	// each greeting is stored, the key has the server replay the response
+	// to a retried request instead of storing it again
+	key, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+	header := http.Header{"Idempotency-Key": {key.String()}}
+	err = c.do(ctx, http.MethodPost, "/v1/greet", header, struct {
+		Name string `json:"name"`
+	}{Name: name}, &resp)
+	return resp.Greeting, err
+}
+
+// ListGreetingsRequest selects a page of ListGreetings, see the
+// ListGreetingsRequest message of the API for the fields.
+type ListGreetingsRequest struct {
+	PageSize  int
+	PageToken string
+	Filter    string
+	OrderBy   string
+}
+
+// Greeting is a stored greeting.
+type Greeting struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Message    string    `json:"message"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+// ListGreetings calls GET /v1/greetings and returns a page of greetings and
+// the token of the next one, empty on the last page.
+func (c *HTTPClient) ListGreetings(ctx context.Context, r ListGreetingsRequest) ([]Greeting, string, error) {
+	q := url.Values{}
+	if r.PageSize > 0 {
+		q.Set("page_size", strconv.Itoa(r.PageSize))
+	}
+	if r.PageToken != "" {
+		q.Set("page_token", r.PageToken)
+	}
+	if r.Filter != "" {
+		q.Set("filter", r.Filter)
+	}
+	if r.OrderBy != "" {
+		q.Set("order_by", r.OrderBy)
+	}
+	path := "/v1/greetings"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+
+	var resp struct {
+		Greetings     []Greeting `json:"greetings"`
+		NextPageToken string     `json:"next_page_token"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &resp); err != nil {
+		return nil, "", err
+	}
+	return resp.Greetings, resp.NextPageToken, nil
+}
+
+func (c *HTTPClient) do(ctx context.Context, method, path string, header http.Header, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		var er struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&er) == nil && er.Error != "" {
+			e.Message = er.Error
+		}
+		return e
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type retryableKey struct{}
+
+// Retryable marks requests made with ctx as safe to retry regardless of their
+// method.
+func Retryable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryableKey{}, true)
+}
+
+// retryTransport retries requests on connection errors and on 429, 502, 503
+// and 504 responses with jittered exponential backoff, honoring Retry-After.
+// Only requests that are safe to repeat are retried: idempotent methods,
+// requests carrying an Idempotency-Key header and those made with a
+// Retryable context.
+type retryTransport struct {
+	base        http.RoundTripper
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.maxAttempts <= 1 || !canRetry(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	backoff := t.backoff
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= t.maxAttempts || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		wait := backoff/2 + rand.N(backoff/2+1)
+		if resp != nil {
+			if ra := retryAfter(resp); ra > 0 {
+				wait = ra
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		if backoff *= 2; backoff > t.maxBackoff {
+			backoff = t.maxBackoff
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+func canRetry(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	if req.Header.Get("Idempotency-Key") != "" {
+		return true
+	}
+	ok, _ := req.Context().Value(retryableKey{}).(bool)
+	return ok
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func retryAfter(resp *http.Response) time.Duration {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	var secs int
+	if _, err := fmt.Sscanf(v, "%d", &secs); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,33 @@
+package zstd
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/encoding"
+)
+
+func TestCompressor(t *testing.T) {
+	c := encoding.GetCompressor(Name)
+	require.NotNil(t, c)
+
+	msg := strings.Repeat("hello gopher ", 100)
+	for i := 0; i < 3; i++ { // pooled encoders and decoders are reused
+		buf := &bytes.Buffer{}
+		w, err := c.Compress(buf)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(msg))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		require.Less(t, buf.Len(), len(msg))
+
+		r, err := c.Decompress(buf)
+		require.NoError(t, err)
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, msg, string(got))
+	}
+}